@@ -0,0 +1,33 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueViolation is the SQLSTATE of unique constraint violations.
+const uniqueViolation = "23505"
+
+// checkViolation is the SQLSTATE of check constraint violations.
+const checkViolation = "23514"
+
+// IsNoRows reports whether the error is caused by a query returning no rows.
+func IsNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
+// IsUniqueViolation reports whether the error is caused by a unique
+// constraint violation.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// IsCheckViolation reports whether the error is caused by a check constraint
+// violation.
+func IsCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == checkViolation
+}
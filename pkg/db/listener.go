@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type listener struct {
+	conn    *pgxpool.Conn
+	channel string
+}
+
+// Listen acquires a dedicated connection from the pool and subscribes it to
+// the given channel. The connection is held until the listener is closed.
+func (c *db) Listen(ctx context.Context, channel string) (Listener, error) {
+	conn, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire listen connection: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to listen on channel %s: %w", channel, err)
+	}
+
+	return &listener{conn: conn, channel: channel}, nil
+}
+
+// WaitForNotification blocks until a notification is received on the channel
+// or the context is done.
+func (l *listener) WaitForNotification(ctx context.Context) (*Notification, error) {
+	n, err := l.conn.Conn().WaitForNotification(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for notification: %w", err)
+	}
+	return &Notification{Channel: n.Channel, Payload: n.Payload}, nil
+}
+
+// Close unsubscribes from the channel and releases the connection back to
+// the pool.
+func (l *listener) Close(ctx context.Context) error {
+	defer l.conn.Release()
+
+	if l.conn.Conn().IsClosed() {
+		return nil
+	}
+	if _, err := l.conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to unlisten channel %s: %w", l.channel, err)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// TryAdvisoryXactLock tries to take the transaction-level advisory lock with
+// the given key without waiting. It reports whether the lock was acquired;
+// the lock is released when the transaction commits or rolls back, so a
+// single replica runs a job per transaction.
+func TryAdvisoryXactLock(ctx context.Context, tx Tx, key int64) (bool, error) {
+	var acquired bool
+	if err := tx.QueryRow(ctx, &acquired, "SELECT pg_try_advisory_xact_lock($1)", key); err != nil {
+		return false, fmt.Errorf("try advisory lock %d: %w", key, err)
+	}
+	return acquired, nil
+}
@@ -38,6 +38,12 @@ func (t *tx) Exec(ctx context.Context, query string, args ...any) (int64, error)
 	return commandTag.RowsAffected(), nil
 }
 
+// QueryRow executes a query within the transaction and scans the single
+// resulting row into the provided destination.
+func (t *tx) QueryRow(ctx context.Context, dest any, query string, args ...any) error {
+	return pgxscan.Get(ctx, t.tx, dest, query, args...)
+}
+
 // Commit commits the current transaction. Returns an error if commit fails.
 func (t *tx) Commit(ctx context.Context) error {
 	return t.tx.Commit(ctx)
@@ -23,14 +23,29 @@ type DB interface {
 	Exec(ctx context.Context, query string, args ...any) (int64, error)
 	QueryRow(ctx context.Context, dest any, query string, args ...any) error
 	BeginTx(ctx context.Context) (Tx, error)
+	Listen(ctx context.Context, channel string) (Listener, error)
 	Ping(ctx context.Context) error
 	Close()
 }
 
+// Listener defines the interface for receiving notifications sent with
+// NOTIFY on a channel.
+type Listener interface {
+	WaitForNotification(ctx context.Context) (*Notification, error)
+	Close(ctx context.Context) error
+}
+
+// Notification represents a message received on a LISTEN channel.
+type Notification struct {
+	Channel string
+	Payload string
+}
+
 // Tx defines the interface for transactional operations.
 type Tx interface {
 	Select(ctx context.Context, dest any, query string, args ...any) error
 	Exec(ctx context.Context, query string, args ...any) (int64, error)
+	QueryRow(ctx context.Context, dest any, query string, args ...any) error
 	Commit(ctx context.Context) error
 	Rollback(ctx context.Context) error
 }
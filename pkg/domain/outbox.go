@@ -6,14 +6,26 @@ import (
 	"github.com/google/uuid"
 )
 
+// Outbox statuses.
+const (
+	OutboxStatusPending = "PENDING"
+	OutboxStatusSent    = "SENT"
+	OutboxStatusDead    = "DEAD"
+)
+
 // Outbox represents a record in the outbox table used for event-driven processing.
 type Outbox struct {
-	ID            int64     `db:"id"`
-	AggregateID   uuid.UUID `db:"aggregate_id"`
-	AggregateType string    `db:"aggregate_type"`
-	EventType     string    `db:"event_type"`
-	Payload       []byte    `db:"payload"` // JSON serializado
-	Status        string    `db:"status"`
-	CreatedAt     time.Time `db:"created_at"`
-	UpdatedAt     time.Time `db:"updated_at"`
+	ID            int64      `db:"id"`
+	AggregateID   uuid.UUID  `db:"aggregate_id"`
+	AggregateType string     `db:"aggregate_type"`
+	EventType     string     `db:"event_type"`
+	Payload       []byte     `db:"payload"` // serialized event, see ContentType
+	ContentType   string     `db:"content_type"`
+	Status        string     `db:"status"`
+	CreatedAt     time.Time  `db:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at"`
+	SentAt        *time.Time `db:"sent_at"`
+	Attempts      int        `db:"attempts"`
+	LastError     *string    `db:"last_error"`
+	NextAttemptAt time.Time  `db:"next_attempt_at"`
 }
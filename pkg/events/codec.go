@@ -0,0 +1,83 @@
+package events
+
+import (
+	"fmt"
+	"mime"
+	"payment-system/pkg/kafka"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Encode encodes the given event with the given content type, either JSON or
+// Protobuf.
+func Encode(e Event, contentType string) ([]byte, error) {
+	switch mediaType(contentType) {
+	case kafka.ContentTypeJSON:
+		return Marshal(e)
+	case kafka.ContentTypeProtobuf:
+		pe, ok := e.(protoEvent)
+		if !ok {
+			return nil, fmt.Errorf("event %s has no protobuf encoding", e.EventType())
+		}
+		data, err := proto.Marshal(pe.toProto())
+		if err != nil {
+			return nil, fmt.Errorf("marshal event %s: %w", e.EventType(), err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
+
+// Decode decodes an event of the given type encoded with the given content
+// type. Data without content type is decoded as JSON.
+func Decode(eventType, contentType string, data []byte) (Event, error) {
+	switch mediaType(contentType) {
+	case "", kafka.ContentTypeJSON:
+		return Unmarshal(eventType, data)
+	case kafka.ContentTypeProtobuf:
+		d, ok := registry[eventType]
+		if !ok {
+			return nil, fmt.Errorf("unknown event type %s", eventType)
+		}
+		pe, ok := d.New().(protoEvent)
+		if !ok {
+			return nil, fmt.Errorf("event %s has no protobuf encoding", eventType)
+		}
+		m := pe.newProto()
+		if err := proto.Unmarshal(data, m); err != nil {
+			return nil, fmt.Errorf("unmarshal event %s: %w", eventType, err)
+		}
+		if err := pe.fromProto(m); err != nil {
+			return nil, fmt.Errorf("unmarshal event %s: %w", eventType, err)
+		}
+		return pe, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
+
+// ContentTypeFor returns the content type of the given encoding name, "json"
+// or "protobuf".
+func ContentTypeFor(encoding string) (string, error) {
+	switch encoding {
+	case "json":
+		return kafka.ContentTypeJSON, nil
+	case "protobuf":
+		return kafka.ContentTypeProtobuf, nil
+	default:
+		return "", fmt.Errorf("unsupported event encoding %q", encoding)
+	}
+}
+
+// mediaType returns the content type without parameters.
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mt
+}
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"fmt"
+	"payment-system/pkg/kafka"
+
+	"github.com/IBM/sarama"
+)
+
+// HandlerFunc processes a decoded event together with the CloudEvent
+// attributes of the message carrying it.
+type HandlerFunc func(meta *kafka.Event, e Event) error
+
+// ConsumerHandler adapts a HandlerFunc to kafka.ConsumerHandler. Messages are
+// decoded according to their content-type header, so JSON and Protobuf
+// encoded events can be consumed side by side.
+type ConsumerHandler struct {
+	handle HandlerFunc
+}
+
+// NewConsumerHandler creates a ConsumerHandler calling handle for every
+// consumed event.
+func NewConsumerHandler(handle HandlerFunc) *ConsumerHandler {
+	return &ConsumerHandler{handle: handle}
+}
+
+// ConsumeMessage decodes the event carried by the message and handles it.
+// Messages that cannot be decoded fail with kafka.ErrPermanent, as
+// consuming them again would not help.
+func (h *ConsumerHandler) ConsumeMessage(msg *sarama.ConsumerMessage) error {
+	meta, e, err := DecodeMessage(msg)
+	if err != nil {
+		return kafka.Permanent(err)
+	}
+	return h.handle(meta, e)
+}
+
+// DecodeMessage reads the CloudEvent attributes of a consumed message and
+// decodes its data based on the ce_type and content-type headers.
+func DecodeMessage(msg *sarama.ConsumerMessage) (*kafka.Event, Event, error) {
+	meta, err := kafka.EventFromMessage(msg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read cloudevent: %w", err)
+	}
+
+	e, err := Decode(meta.Type, meta.ContentType, meta.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return meta, e, nil
+}
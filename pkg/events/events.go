@@ -0,0 +1,96 @@
+// Package events declares the versioned events exchanged between services
+// through Kafka.
+//
+// Every event is an explicit Go type with a versioned type name such as
+// "payment.requested.v1", which is used as the outbox event type and the
+// CloudEvents type. A published version never changes in a breaking way: the
+// JSON Schema of every registered event is checked at test time against the
+// snapshot in the schemas directory, and breaking changes require a new
+// version with a new type.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Event is implemented by every event published on Kafka.
+type Event interface {
+	// EventType returns the versioned type of the event.
+	EventType() string
+}
+
+// Descriptor describes a registered event type.
+type Descriptor struct {
+	// Type is the versioned type of the event, e.g. "payment.requested.v1".
+	Type string
+	// Topic is the Kafka topic the event is published to.
+	Topic string
+	// New returns a new zero value of the event.
+	New func() Event
+}
+
+// registry holds the registered event types by type.
+var registry = map[string]Descriptor{}
+
+// Register adds an event type to the registry. It panics if the type is
+// already registered, as it is only called during initialization.
+func Register(topic string, newEvent func() Event) {
+	eventType := newEvent().EventType()
+	if _, ok := registry[eventType]; ok {
+		panic(fmt.Sprintf("events: type %s already registered", eventType))
+	}
+	registry[eventType] = Descriptor{Type: eventType, Topic: topic, New: newEvent}
+}
+
+// Lookup returns the descriptor of the given event type.
+func Lookup(eventType string) (Descriptor, bool) {
+	d, ok := registry[eventType]
+	return d, ok
+}
+
+// Descriptors returns all registered event types sorted by type.
+func Descriptors() []Descriptor {
+	descriptors := make([]Descriptor, 0, len(registry))
+	for _, d := range registry {
+		descriptors = append(descriptors, d)
+	}
+	sort.Slice(descriptors, func(i, j int) bool {
+		return descriptors[i].Type < descriptors[j].Type
+	})
+	return descriptors
+}
+
+// Topic returns the Kafka topic the given event type is published to. Unknown
+// event types are published to a topic derived from the type itself.
+func Topic(eventType string) string {
+	if d, ok := registry[eventType]; ok {
+		return d.Topic
+	}
+	return strings.ReplaceAll(eventType, "_", ".")
+}
+
+// Marshal encodes the given event as JSON.
+func Marshal(e Event) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("marshal event %s: %w", e.EventType(), err)
+	}
+	return data, nil
+}
+
+// Unmarshal decodes a JSON encoded event of the given type.
+func Unmarshal(eventType string, data []byte) (Event, error) {
+	d, ok := registry[eventType]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %s", eventType)
+	}
+
+	e := d.New()
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("unmarshal event %s: %w", eventType, err)
+	}
+	return e, nil
+}
@@ -0,0 +1,222 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"payment-system/pkg/kafka"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+)
+
+var update = flag.Bool("update", false, "write the schema snapshots of new or compatible events")
+
+// TestSchemasCompatible checks every registered event against its schema
+// snapshot, failing when a change would break existing consumers. Run with
+// -update to write the snapshots of new event types.
+func TestSchemasCompatible(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, d := range Descriptors() {
+		registered[d.Type] = true
+
+		current, err := GenerateSchema(d.New())
+		require.NoError(t, err)
+		path := filepath.Join("schemas", d.Type+".json")
+
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			if !*update {
+				t.Errorf("%s: missing schema snapshot, run go test -run TestSchemasCompatible -update", d.Type)
+				continue
+			}
+		} else {
+			require.NoError(t, err)
+			var previous Schema
+			require.NoError(t, json.Unmarshal(data, &previous))
+			if err := CheckCompatibility(&previous, current); err != nil {
+				t.Errorf("%s: incompatible schema change, publish a new version instead:\n%v", d.Type, err)
+				continue
+			}
+		}
+
+		if *update {
+			data, err := current.MarshalIndent()
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, data, 0644))
+		}
+	}
+
+	// published events must remain registered.
+	snapshots, err := filepath.Glob(filepath.Join("schemas", "*.json"))
+	require.NoError(t, err)
+	for _, path := range snapshots {
+		eventType := strings.TrimSuffix(filepath.Base(path), ".json")
+		if !registered[eventType] {
+			t.Errorf("%s: event type has a schema snapshot but is no longer registered", eventType)
+		}
+	}
+}
+
+// TestCheckCompatibility checks which schema changes are considered breaking.
+func TestCheckCompatibility(t *testing.T) {
+	previous := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"payment_id": {Type: "string", Format: "uuid"},
+			"amount":     {Type: "string"},
+			"reason":     {Type: "string"},
+		},
+		Required: []string{"amount", "payment_id"},
+	}
+
+	// adding an optional property is compatible.
+	current := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"payment_id": {Type: "string", Format: "uuid"},
+			"amount":     {Type: "string"},
+			"reason":     {Type: "string"},
+			"note":       {Type: "string"},
+		},
+		Required: []string{"amount", "payment_id"},
+	}
+	require.NoError(t, CheckCompatibility(previous, current))
+
+	// removing, retyping or requiring properties is not.
+	current = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"payment_id": {Type: "string", Format: "uuid"},
+			"amount":     {Type: "number"},
+			"code":       {Type: "string"},
+		},
+		Required: []string{"amount", "code", "payment_id"},
+	}
+	err := CheckCompatibility(previous, current)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "$.amount: type changed from string to number"))
+	require.True(t, strings.Contains(err.Error(), "$.reason: removed"))
+	require.True(t, strings.Contains(err.Error(), "$.code: became required"))
+}
+
+// TestGenerateSchema checks the schema generated from an event type.
+func TestGenerateSchema(t *testing.T) {
+	s, err := GenerateSchema(&PaymentFinalizedV1{})
+	require.NoError(t, err)
+
+	require.Equal(t, TypePaymentFinalizedV1, s.Title)
+	require.Equal(t, "object", s.Type)
+	require.Equal(t, &Schema{Type: "string", Format: "uuid"}, s.Properties["payment_id"])
+	require.Equal(t, &Schema{Type: "string", Format: "date-time"}, s.Properties["finalized_at"])
+	require.Equal(t, &Schema{Type: "integer"}, s.Properties["user_id"])
+	require.NotContains(t, s.Required, "failure_reason")
+	require.Contains(t, s.Required, "status")
+}
+
+// TestMarshalUnmarshal checks that a registered event can be decoded by type
+// and is routed to its topic.
+func TestMarshalUnmarshal(t *testing.T) {
+	event := &PaymentRequestedV1{
+		PaymentID:       uuid.New(),
+		ExternalOrderID: uuid.New(),
+		UserID:          1,
+		Amount:          "99.99",
+		Currency:        "USD",
+		RequestedAt:     time.Now().UTC().Truncate(time.Second),
+	}
+
+	data, err := Marshal(event)
+	require.NoError(t, err)
+
+	decoded, err := Unmarshal(TypePaymentRequestedV1, data)
+	require.NoError(t, err)
+	require.Equal(t, event, decoded)
+	require.Equal(t, TopicPaymentsRequested, Topic(event.EventType()))
+
+	_, err = Unmarshal("unknown.v1", data)
+	require.Error(t, err)
+}
+
+// TestEncodeDecode checks that every registered event survives a round trip
+// through both the JSON and the Protobuf encodings.
+func TestEncodeDecode(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	samples := []Event{
+		&PaymentRequestedV1{PaymentID: uuid.New(), ExternalOrderID: uuid.New(), UserID: 1,
+			Amount: "10.00", Currency: "USD", RequestedAt: now},
+		&PaymentFinalizedV1{PaymentID: uuid.New(), UserID: 1, Status: "FAILED", Amount: "10.00",
+			Currency: "USD", FailureReason: "insufficient funds", FinalizedAt: now},
+		&FundsReservedV1{PaymentID: uuid.New(), UserID: 1, HoldID: uuid.New(), Amount: "10.00",
+			Currency: "USD", ReservedAt: now},
+		&FundsInsufficientV1{PaymentID: uuid.New(), UserID: 1, Amount: "10.00", Currency: "USD",
+			Reason: "balance too low", OccurredAt: now},
+		&FundsReleasedV1{PaymentID: uuid.New(), UserID: 1, HoldID: uuid.New(), Amount: "10.00",
+			Currency: "USD", Reason: "hold expired", ReleasedAt: now},
+		&WalletCreditedV1{WalletID: uuid.New(), UserID: 1, OperationID: uuid.New(), Kind: "TOPUP",
+			Amount: "25.00", Currency: "USD", Balance: "35.00", CreditedAt: now},
+		&WalletDebitedV1{WalletID: uuid.New(), UserID: 1, OperationID: uuid.New(), Kind: "WITHDRAWAL",
+			Amount: "5.00", Currency: "USD", Balance: "30.00", DebitedAt: now},
+		&TransferCompletedV1{TransferID: uuid.New(), FromWalletID: uuid.New(), FromUserID: 1,
+			ToWalletID: uuid.New(), ToUserID: 2, Amount: "7.50", Currency: "USD", CompletedAt: now},
+		&PaymentCompletedV1{PaymentID: uuid.New(), UserID: 1, TransactionID: "tx-1",
+			Amount: "10.00", Currency: "USD", CompletedAt: now},
+		&PaymentFailedV1{PaymentID: uuid.New(), UserID: 1, Reason: "declined", Code: "05", FailedAt: now},
+		&RefundRequestedV1{RefundID: uuid.New(), PaymentID: uuid.New(), UserID: 1, Amount: "5.00",
+			Currency: "USD", Reason: "damaged", RequestedAt: now},
+		&PaymentCancelledV1{PaymentID: uuid.New(), UserID: 1, Amount: "10.00", Currency: "USD",
+			Reason: "changed my mind", CancelledAt: now},
+	}
+	require.Len(t, samples, len(Descriptors()))
+
+	for _, contentType := range []string{kafka.ContentTypeJSON, kafka.ContentTypeProtobuf} {
+		for _, event := range samples {
+			data, err := Encode(event, contentType)
+			require.NoError(t, err)
+
+			decoded, err := Decode(event.EventType(), contentType, data)
+			require.NoError(t, err)
+			require.Equal(t, event, decoded)
+		}
+	}
+
+	_, err := Encode(samples[0], "text/plain")
+	require.Error(t, err)
+	_, err = Decode(TypePaymentRequestedV1, kafka.ContentTypeProtobuf, []byte("{"))
+	require.Error(t, err)
+}
+
+// TestDecodeMessage checks that consumed messages are decoded according to
+// their content-type header.
+func TestDecodeMessage(t *testing.T) {
+	event := &PaymentFailedV1{PaymentID: uuid.New(), UserID: 2, Reason: "declined",
+		FailedAt: time.Now().UTC().Truncate(time.Microsecond)}
+
+	for _, contentType := range []string{kafka.ContentTypeJSON, kafka.ContentTypeProtobuf} {
+		data, err := Encode(event, contentType)
+		require.NoError(t, err)
+
+		meta := &kafka.Event{ID: "1", Type: event.EventType(), Source: "/test",
+			ContentType: contentType, Data: data}
+		var headers []*sarama.RecordHeader
+		for _, h := range meta.Headers() {
+			headers = append(headers, &h)
+		}
+
+		var handled Event
+		handler := NewConsumerHandler(func(m *kafka.Event, e Event) error {
+			require.Equal(t, contentType, m.ContentType)
+			handled = e
+			return nil
+		})
+		err = handler.ConsumeMessage(&sarama.ConsumerMessage{Headers: headers, Value: data})
+		require.NoError(t, err)
+		require.Equal(t, event, handled)
+	}
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Topics of the events published by the payment service.
+const (
+	TopicPaymentsRequested = "payments.requested"
+	TopicPaymentsFinalized = "payments.finalized"
+	TopicRefundsRequested  = "refunds.requested"
+	TopicPaymentsCancelled = "payments.cancelled"
+)
+
+// Types of the events published by the payment service.
+const (
+	TypePaymentRequestedV1 = "payment.requested.v1"
+	TypePaymentFinalizedV1 = "payment.finalized.v1"
+	TypeRefundRequestedV1  = "refund.requested.v1"
+	TypePaymentCancelledV1 = "payment.cancelled.v1"
+)
+
+func init() {
+	Register(TopicPaymentsRequested, func() Event { return &PaymentRequestedV1{} })
+	Register(TopicPaymentsFinalized, func() Event { return &PaymentFinalizedV1{} })
+	Register(TopicRefundsRequested, func() Event { return &RefundRequestedV1{} })
+	Register(TopicPaymentsCancelled, func() Event { return &PaymentCancelledV1{} })
+}
+
+// PaymentRequestedV1 is published when a payment is created, to start the
+// payment saga.
+type PaymentRequestedV1 struct {
+	PaymentID       uuid.UUID `json:"payment_id"`
+	ExternalOrderID uuid.UUID `json:"external_order_id"`
+	UserID          uint32    `json:"user_id"`
+	Amount          string    `json:"amount"`
+	Currency        string    `json:"currency"`
+	RequestedAt     time.Time `json:"requested_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*PaymentRequestedV1) EventType() string { return TypePaymentRequestedV1 }
+
+// PaymentFinalizedV1 is published with the final, immutable state of a payment.
+type PaymentFinalizedV1 struct {
+	PaymentID     uuid.UUID `json:"payment_id"`
+	UserID        uint32    `json:"user_id"`
+	Status        string    `json:"status"`
+	Amount        string    `json:"amount"`
+	Currency      string    `json:"currency"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	FinalizedAt   time.Time `json:"finalized_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*PaymentFinalizedV1) EventType() string { return TypePaymentFinalizedV1 }
+
+// RefundRequestedV1 is published when a full or partial refund of a completed
+// payment is requested, for the wallet and the processor to return the funds.
+type RefundRequestedV1 struct {
+	RefundID    uuid.UUID `json:"refund_id"`
+	PaymentID   uuid.UUID `json:"payment_id"`
+	UserID      uint32    `json:"user_id"`
+	Amount      string    `json:"amount"`
+	Currency    string    `json:"currency"`
+	Reason      string    `json:"reason,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*RefundRequestedV1) EventType() string { return TypeRefundRequestedV1 }
+
+// PaymentCancelledV1 is published when a payment is cancelled before being
+// charged, for the wallet to release any funds held for it.
+type PaymentCancelledV1 struct {
+	PaymentID   uuid.UUID `json:"payment_id"`
+	UserID      uint32    `json:"user_id"`
+	Amount      string    `json:"amount"`
+	Currency    string    `json:"currency"`
+	Reason      string    `json:"reason,omitempty"`
+	CancelledAt time.Time `json:"cancelled_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*PaymentCancelledV1) EventType() string { return TypePaymentCancelledV1 }
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Topics of the events published by the payment processor.
+const (
+	TopicPaymentCompleted = "payment.completed"
+	TopicPaymentFailed    = "payment.failed"
+)
+
+// Types of the events published by the payment processor.
+const (
+	TypePaymentCompletedV1 = "payment.completed.v1"
+	TypePaymentFailedV1    = "payment.failed.v1"
+)
+
+func init() {
+	Register(TopicPaymentCompleted, func() Event { return &PaymentCompletedV1{} })
+	Register(TopicPaymentFailed, func() Event { return &PaymentFailedV1{} })
+}
+
+// PaymentCompletedV1 is published when the external gateway approves a
+// payment.
+type PaymentCompletedV1 struct {
+	PaymentID     uuid.UUID `json:"payment_id"`
+	UserID        uint32    `json:"user_id"`
+	TransactionID string    `json:"transaction_id"`
+	Amount        string    `json:"amount"`
+	Currency      string    `json:"currency"`
+	CompletedAt   time.Time `json:"completed_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*PaymentCompletedV1) EventType() string { return TypePaymentCompletedV1 }
+
+// PaymentFailedV1 is published when the external gateway rejects a payment
+// or cannot be reached.
+type PaymentFailedV1 struct {
+	PaymentID uuid.UUID `json:"payment_id"`
+	UserID    uint32    `json:"user_id"`
+	Reason    string    `json:"reason"`
+	Code      string    `json:"code,omitempty"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*PaymentFailedV1) EventType() string { return TypePaymentFailedV1 }
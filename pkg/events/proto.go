@@ -0,0 +1,376 @@
+package events
+
+import (
+	"fmt"
+	"payment-system/pkg/events/eventspb"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc --proto_path=proto --go_out=eventspb --go_opt=paths=source_relative payment.proto processor.proto wallet.proto
+
+// protoEvent is implemented by events with a Protobuf representation.
+type protoEvent interface {
+	Event
+	toProto() proto.Message
+	fromProto(m proto.Message) error
+	newProto() proto.Message
+}
+
+func (e *PaymentRequestedV1) newProto() proto.Message { return &eventspb.PaymentRequested{} }
+
+func (e *PaymentRequestedV1) toProto() proto.Message {
+	return &eventspb.PaymentRequested{
+		PaymentId:       e.PaymentID.String(),
+		ExternalOrderId: e.ExternalOrderID.String(),
+		UserId:          e.UserID,
+		Amount:          e.Amount,
+		Currency:        e.Currency,
+		RequestedAt:     timestamppb.New(e.RequestedAt),
+	}
+}
+
+func (e *PaymentRequestedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.PaymentRequested)
+	var err error
+	if e.PaymentID, err = uuid.Parse(pb.PaymentId); err != nil {
+		return fmt.Errorf("invalid payment_id: %w", err)
+	}
+	if e.ExternalOrderID, err = uuid.Parse(pb.ExternalOrderId); err != nil {
+		return fmt.Errorf("invalid external_order_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.RequestedAt = pb.RequestedAt.AsTime()
+	return nil
+}
+
+func (e *PaymentFinalizedV1) newProto() proto.Message { return &eventspb.PaymentFinalized{} }
+
+func (e *PaymentFinalizedV1) toProto() proto.Message {
+	return &eventspb.PaymentFinalized{
+		PaymentId:     e.PaymentID.String(),
+		UserId:        e.UserID,
+		Status:        e.Status,
+		Amount:        e.Amount,
+		Currency:      e.Currency,
+		FailureReason: e.FailureReason,
+		FinalizedAt:   timestamppb.New(e.FinalizedAt),
+	}
+}
+
+func (e *PaymentFinalizedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.PaymentFinalized)
+	var err error
+	if e.PaymentID, err = uuid.Parse(pb.PaymentId); err != nil {
+		return fmt.Errorf("invalid payment_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Status = pb.Status
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.FailureReason = pb.FailureReason
+	e.FinalizedAt = pb.FinalizedAt.AsTime()
+	return nil
+}
+
+func (e *RefundRequestedV1) newProto() proto.Message { return &eventspb.RefundRequested{} }
+
+func (e *RefundRequestedV1) toProto() proto.Message {
+	return &eventspb.RefundRequested{
+		RefundId:    e.RefundID.String(),
+		PaymentId:   e.PaymentID.String(),
+		UserId:      e.UserID,
+		Amount:      e.Amount,
+		Currency:    e.Currency,
+		Reason:      e.Reason,
+		RequestedAt: timestamppb.New(e.RequestedAt),
+	}
+}
+
+func (e *RefundRequestedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.RefundRequested)
+	var err error
+	if e.RefundID, err = uuid.Parse(pb.RefundId); err != nil {
+		return fmt.Errorf("invalid refund_id: %w", err)
+	}
+	if e.PaymentID, err = uuid.Parse(pb.PaymentId); err != nil {
+		return fmt.Errorf("invalid payment_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.Reason = pb.Reason
+	e.RequestedAt = pb.RequestedAt.AsTime()
+	return nil
+}
+
+func (e *PaymentCancelledV1) newProto() proto.Message { return &eventspb.PaymentCancelled{} }
+
+func (e *PaymentCancelledV1) toProto() proto.Message {
+	return &eventspb.PaymentCancelled{
+		PaymentId:   e.PaymentID.String(),
+		UserId:      e.UserID,
+		Amount:      e.Amount,
+		Currency:    e.Currency,
+		Reason:      e.Reason,
+		CancelledAt: timestamppb.New(e.CancelledAt),
+	}
+}
+
+func (e *PaymentCancelledV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.PaymentCancelled)
+	var err error
+	if e.PaymentID, err = uuid.Parse(pb.PaymentId); err != nil {
+		return fmt.Errorf("invalid payment_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.Reason = pb.Reason
+	e.CancelledAt = pb.CancelledAt.AsTime()
+	return nil
+}
+
+func (e *FundsReservedV1) newProto() proto.Message { return &eventspb.FundsReserved{} }
+
+func (e *FundsReservedV1) toProto() proto.Message {
+	return &eventspb.FundsReserved{
+		PaymentId:  e.PaymentID.String(),
+		UserId:     e.UserID,
+		HoldId:     e.HoldID.String(),
+		Amount:     e.Amount,
+		Currency:   e.Currency,
+		ReservedAt: timestamppb.New(e.ReservedAt),
+	}
+}
+
+func (e *FundsReservedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.FundsReserved)
+	var err error
+	if e.PaymentID, err = uuid.Parse(pb.PaymentId); err != nil {
+		return fmt.Errorf("invalid payment_id: %w", err)
+	}
+	if e.HoldID, err = uuid.Parse(pb.HoldId); err != nil {
+		return fmt.Errorf("invalid hold_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.ReservedAt = pb.ReservedAt.AsTime()
+	return nil
+}
+
+func (e *FundsInsufficientV1) newProto() proto.Message { return &eventspb.FundsInsufficient{} }
+
+func (e *FundsInsufficientV1) toProto() proto.Message {
+	return &eventspb.FundsInsufficient{
+		PaymentId:  e.PaymentID.String(),
+		UserId:     e.UserID,
+		Amount:     e.Amount,
+		Currency:   e.Currency,
+		Reason:     e.Reason,
+		OccurredAt: timestamppb.New(e.OccurredAt),
+	}
+}
+
+func (e *FundsInsufficientV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.FundsInsufficient)
+	var err error
+	if e.PaymentID, err = uuid.Parse(pb.PaymentId); err != nil {
+		return fmt.Errorf("invalid payment_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.Reason = pb.Reason
+	e.OccurredAt = pb.OccurredAt.AsTime()
+	return nil
+}
+
+func (e *FundsReleasedV1) newProto() proto.Message { return &eventspb.FundsReleased{} }
+
+func (e *FundsReleasedV1) toProto() proto.Message {
+	return &eventspb.FundsReleased{
+		PaymentId:  e.PaymentID.String(),
+		UserId:     e.UserID,
+		HoldId:     e.HoldID.String(),
+		Amount:     e.Amount,
+		Currency:   e.Currency,
+		Reason:     e.Reason,
+		ReleasedAt: timestamppb.New(e.ReleasedAt),
+	}
+}
+
+func (e *FundsReleasedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.FundsReleased)
+	var err error
+	if e.PaymentID, err = uuid.Parse(pb.PaymentId); err != nil {
+		return fmt.Errorf("invalid payment_id: %w", err)
+	}
+	if e.HoldID, err = uuid.Parse(pb.HoldId); err != nil {
+		return fmt.Errorf("invalid hold_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.Reason = pb.Reason
+	e.ReleasedAt = pb.ReleasedAt.AsTime()
+	return nil
+}
+
+func (e *WalletCreditedV1) newProto() proto.Message { return &eventspb.WalletCredited{} }
+
+func (e *WalletCreditedV1) toProto() proto.Message {
+	return &eventspb.WalletCredited{
+		WalletId:    e.WalletID.String(),
+		UserId:      e.UserID,
+		OperationId: e.OperationID.String(),
+		Kind:        e.Kind,
+		Amount:      e.Amount,
+		Currency:    e.Currency,
+		Balance:     e.Balance,
+		CreditedAt:  timestamppb.New(e.CreditedAt),
+	}
+}
+
+func (e *WalletCreditedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.WalletCredited)
+	var err error
+	if e.WalletID, err = uuid.Parse(pb.WalletId); err != nil {
+		return fmt.Errorf("invalid wallet_id: %w", err)
+	}
+	if e.OperationID, err = uuid.Parse(pb.OperationId); err != nil {
+		return fmt.Errorf("invalid operation_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Kind = pb.Kind
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.Balance = pb.Balance
+	e.CreditedAt = pb.CreditedAt.AsTime()
+	return nil
+}
+
+func (e *WalletDebitedV1) newProto() proto.Message { return &eventspb.WalletDebited{} }
+
+func (e *WalletDebitedV1) toProto() proto.Message {
+	return &eventspb.WalletDebited{
+		WalletId:    e.WalletID.String(),
+		UserId:      e.UserID,
+		OperationId: e.OperationID.String(),
+		Kind:        e.Kind,
+		Amount:      e.Amount,
+		Currency:    e.Currency,
+		Balance:     e.Balance,
+		DebitedAt:   timestamppb.New(e.DebitedAt),
+	}
+}
+
+func (e *WalletDebitedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.WalletDebited)
+	var err error
+	if e.WalletID, err = uuid.Parse(pb.WalletId); err != nil {
+		return fmt.Errorf("invalid wallet_id: %w", err)
+	}
+	if e.OperationID, err = uuid.Parse(pb.OperationId); err != nil {
+		return fmt.Errorf("invalid operation_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Kind = pb.Kind
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.Balance = pb.Balance
+	e.DebitedAt = pb.DebitedAt.AsTime()
+	return nil
+}
+
+func (e *TransferCompletedV1) newProto() proto.Message { return &eventspb.TransferCompleted{} }
+
+func (e *TransferCompletedV1) toProto() proto.Message {
+	return &eventspb.TransferCompleted{
+		TransferId:   e.TransferID.String(),
+		FromWalletId: e.FromWalletID.String(),
+		FromUserId:   e.FromUserID,
+		ToWalletId:   e.ToWalletID.String(),
+		ToUserId:     e.ToUserID,
+		Amount:       e.Amount,
+		Currency:     e.Currency,
+		CompletedAt:  timestamppb.New(e.CompletedAt),
+	}
+}
+
+func (e *TransferCompletedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.TransferCompleted)
+	var err error
+	if e.TransferID, err = uuid.Parse(pb.TransferId); err != nil {
+		return fmt.Errorf("invalid transfer_id: %w", err)
+	}
+	if e.FromWalletID, err = uuid.Parse(pb.FromWalletId); err != nil {
+		return fmt.Errorf("invalid from_wallet_id: %w", err)
+	}
+	if e.ToWalletID, err = uuid.Parse(pb.ToWalletId); err != nil {
+		return fmt.Errorf("invalid to_wallet_id: %w", err)
+	}
+	e.FromUserID = pb.FromUserId
+	e.ToUserID = pb.ToUserId
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.CompletedAt = pb.CompletedAt.AsTime()
+	return nil
+}
+
+func (e *PaymentCompletedV1) newProto() proto.Message { return &eventspb.PaymentCompleted{} }
+
+func (e *PaymentCompletedV1) toProto() proto.Message {
+	return &eventspb.PaymentCompleted{
+		PaymentId:     e.PaymentID.String(),
+		UserId:        e.UserID,
+		TransactionId: e.TransactionID,
+		Amount:        e.Amount,
+		Currency:      e.Currency,
+		CompletedAt:   timestamppb.New(e.CompletedAt),
+	}
+}
+
+func (e *PaymentCompletedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.PaymentCompleted)
+	var err error
+	if e.PaymentID, err = uuid.Parse(pb.PaymentId); err != nil {
+		return fmt.Errorf("invalid payment_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.TransactionID = pb.TransactionId
+	e.Amount = pb.Amount
+	e.Currency = pb.Currency
+	e.CompletedAt = pb.CompletedAt.AsTime()
+	return nil
+}
+
+func (e *PaymentFailedV1) newProto() proto.Message { return &eventspb.PaymentFailed{} }
+
+func (e *PaymentFailedV1) toProto() proto.Message {
+	return &eventspb.PaymentFailed{
+		PaymentId: e.PaymentID.String(),
+		UserId:    e.UserID,
+		Reason:    e.Reason,
+		Code:      e.Code,
+		FailedAt:  timestamppb.New(e.FailedAt),
+	}
+}
+
+func (e *PaymentFailedV1) fromProto(m proto.Message) error {
+	pb := m.(*eventspb.PaymentFailed)
+	var err error
+	if e.PaymentID, err = uuid.Parse(pb.PaymentId); err != nil {
+		return fmt.Errorf("invalid payment_id: %w", err)
+	}
+	e.UserID = pb.UserId
+	e.Reason = pb.Reason
+	e.Code = pb.Code
+	e.FailedAt = pb.FailedAt.AsTime()
+	return nil
+}
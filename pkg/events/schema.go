@@ -0,0 +1,182 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// jsonSchemaDraft is the JSON Schema dialect of the generated schemas.
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema used to describe events.
+type Schema struct {
+	Draft      string             `json:"$schema,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+}
+
+// SchemaProvider is implemented by types that describe their own JSON
+// representation, such as types with a custom JSON encoding.
+type SchemaProvider interface {
+	JSONSchema() *Schema
+}
+
+var (
+	timeType           = reflect.TypeOf(time.Time{})
+	uuidType           = reflect.TypeOf(uuid.UUID{})
+	schemaProviderType = reflect.TypeOf((*SchemaProvider)(nil)).Elem()
+)
+
+// GenerateSchema returns the JSON Schema of the given event, derived from its
+// Go type and JSON tags. Fields without omitempty are required.
+func GenerateSchema(e Event) (*Schema, error) {
+	s, err := schemaOf(reflect.TypeOf(e))
+	if err != nil {
+		return nil, fmt.Errorf("generate schema of %s: %w", e.EventType(), err)
+	}
+	s.Draft = jsonSchemaDraft
+	s.Title = e.EventType()
+	return s, nil
+}
+
+func schemaOf(t reflect.Type) (*Schema, error) {
+	if t.Implements(schemaProviderType) {
+		return reflect.Zero(t).Interface().(SchemaProvider).JSONSchema(), nil
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+func structSchema(t reflect.Type) (*Schema, error) {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop, err := schemaOf(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		s.Properties[name] = prop
+
+		optional := f.Type.Kind() == reflect.Pointer ||
+			slices.Contains(strings.Split(opts, ","), "omitempty")
+		if !optional {
+			s.Required = append(s.Required, name)
+		}
+	}
+	slices.Sort(s.Required)
+	return s, nil
+}
+
+// MarshalIndent encodes the schema as indented JSON.
+func (s *Schema) MarshalIndent() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// CheckCompatibility returns an error describing every change from the
+// previous schema that would break consumers or prevent reading events
+// already published: removed properties, changed types or formats, and
+// properties that became required or optional. Adding optional properties is
+// the only compatible change.
+func CheckCompatibility(previous, current *Schema) error {
+	return errors.Join(checkCompatibility("$", previous, current)...)
+}
+
+func checkCompatibility(path string, previous, current *Schema) []error {
+	if current == nil {
+		return []error{fmt.Errorf("%s: removed", path)}
+	}
+	if previous.Type != current.Type {
+		return []error{fmt.Errorf("%s: type changed from %s to %s",
+			path, previous.Type, current.Type)}
+	}
+
+	var errs []error
+	if previous.Format != current.Format {
+		errs = append(errs, fmt.Errorf("%s: format changed from %q to %q",
+			path, previous.Format, current.Format))
+	}
+
+	names := make([]string, 0, len(previous.Properties))
+	for name := range previous.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		errs = append(errs, checkCompatibility(path+"."+name,
+			previous.Properties[name], current.Properties[name])...)
+	}
+	for _, name := range current.Required {
+		if !slices.Contains(previous.Required, name) {
+			errs = append(errs, fmt.Errorf("%s.%s: became required", path, name))
+		}
+	}
+	for _, name := range previous.Required {
+		if !slices.Contains(current.Required, name) {
+			errs = append(errs, fmt.Errorf("%s.%s: is no longer required", path, name))
+		}
+	}
+
+	if previous.Items != nil {
+		errs = append(errs, checkCompatibility(path+"[]", previous.Items, current.Items)...)
+	}
+	return errs
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Topics of the events published by the wallet service.
+const (
+	TopicFundsReserved     = "funds.reserved"
+	TopicFundsInsufficient = "funds.insufficient"
+	TopicFundsReleased     = "funds.released"
+	TopicWalletCredited    = "wallet.credited"
+	TopicWalletDebited     = "wallet.debited"
+	TopicTransferCompleted = "wallet.transfer_completed"
+)
+
+// Types of the events published by the wallet service.
+const (
+	TypeFundsReservedV1     = "funds.reserved.v1"
+	TypeFundsInsufficientV1 = "funds.insufficient.v1"
+	TypeFundsReleasedV1     = "funds.released.v1"
+	TypeWalletCreditedV1    = "wallet.credited.v1"
+	TypeWalletDebitedV1     = "wallet.debited.v1"
+	TypeTransferCompletedV1 = "wallet.transfer_completed.v1"
+)
+
+func init() {
+	Register(TopicFundsReserved, func() Event { return &FundsReservedV1{} })
+	Register(TopicFundsInsufficient, func() Event { return &FundsInsufficientV1{} })
+	Register(TopicFundsReleased, func() Event { return &FundsReleasedV1{} })
+	Register(TopicWalletCredited, func() Event { return &WalletCreditedV1{} })
+	Register(TopicWalletDebited, func() Event { return &WalletDebitedV1{} })
+	Register(TopicTransferCompleted, func() Event { return &TransferCompletedV1{} })
+}
+
+// FundsReservedV1 is published when the funds of a payment are held in the
+// user's wallet.
+type FundsReservedV1 struct {
+	PaymentID  uuid.UUID `json:"payment_id"`
+	UserID     uint32    `json:"user_id"`
+	HoldID     uuid.UUID `json:"hold_id"`
+	Amount     string    `json:"amount"`
+	Currency   string    `json:"currency"`
+	ReservedAt time.Time `json:"reserved_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*FundsReservedV1) EventType() string { return TypeFundsReservedV1 }
+
+// FundsInsufficientV1 is published when the user's wallet does not have
+// enough available funds for a payment.
+type FundsInsufficientV1 struct {
+	PaymentID  uuid.UUID `json:"payment_id"`
+	UserID     uint32    `json:"user_id"`
+	Amount     string    `json:"amount"`
+	Currency   string    `json:"currency"`
+	Reason     string    `json:"reason"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*FundsInsufficientV1) EventType() string { return TypeFundsInsufficientV1 }
+
+// FundsReleasedV1 is published when the wallet releases the funds held for a
+// payment on its own, e.g. because the hold expired, so the payment can no
+// longer be captured.
+type FundsReleasedV1 struct {
+	PaymentID  uuid.UUID `json:"payment_id"`
+	UserID     uint32    `json:"user_id"`
+	HoldID     uuid.UUID `json:"hold_id"`
+	Amount     string    `json:"amount"`
+	Currency   string    `json:"currency"`
+	Reason     string    `json:"reason"`
+	ReleasedAt time.Time `json:"released_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*FundsReleasedV1) EventType() string { return TypeFundsReleasedV1 }
+
+// WalletCreditedV1 is published when funds are added to the available
+// balance of a wallet, e.g. by a top-up.
+type WalletCreditedV1 struct {
+	WalletID    uuid.UUID `json:"wallet_id"`
+	UserID      uint32    `json:"user_id"`
+	OperationID uuid.UUID `json:"operation_id"`
+	// Kind is the operation that credited the wallet, e.g. TOPUP.
+	Kind     string `json:"kind"`
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+	// Balance is the available balance after the credit.
+	Balance    string    `json:"balance"`
+	CreditedAt time.Time `json:"credited_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*WalletCreditedV1) EventType() string { return TypeWalletCreditedV1 }
+
+// WalletDebitedV1 is published when funds are taken from the available
+// balance of a wallet, e.g. by a withdrawal.
+type WalletDebitedV1 struct {
+	WalletID    uuid.UUID `json:"wallet_id"`
+	UserID      uint32    `json:"user_id"`
+	OperationID uuid.UUID `json:"operation_id"`
+	// Kind is the operation that debited the wallet, e.g. WITHDRAWAL.
+	Kind     string `json:"kind"`
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+	// Balance is the available balance after the debit.
+	Balance   string    `json:"balance"`
+	DebitedAt time.Time `json:"debited_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*WalletDebitedV1) EventType() string { return TypeWalletDebitedV1 }
+
+// TransferCompletedV1 is published when funds are moved from the available
+// balance of a wallet to the available balance of another one.
+type TransferCompletedV1 struct {
+	TransferID   uuid.UUID `json:"transfer_id"`
+	FromWalletID uuid.UUID `json:"from_wallet_id"`
+	FromUserID   uint32    `json:"from_user_id"`
+	ToWalletID   uuid.UUID `json:"to_wallet_id"`
+	ToUserID     uint32    `json:"to_user_id"`
+	Amount       string    `json:"amount"`
+	Currency     string    `json:"currency"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
+
+// EventType returns the versioned type of the event.
+func (*TransferCompletedV1) EventType() string { return TypeTransferCompletedV1 }
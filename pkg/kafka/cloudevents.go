@@ -0,0 +1,118 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// SpecVersion is the CloudEvents specification version of published events.
+const SpecVersion = "1.0"
+
+// Content types of the event data.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/protobuf"
+)
+
+// Kafka headers of the CloudEvents 1.0 Kafka protocol binding, binary mode.
+const (
+	HeaderID          = "ce_id"
+	HeaderType        = "ce_type"
+	HeaderSource      = "ce_source"
+	HeaderSubject     = "ce_subject"
+	HeaderTime        = "ce_time"
+	HeaderSpecVersion = "ce_specversion"
+	HeaderContentType = "content-type"
+)
+
+// Event represents a CloudEvent carried in binary content mode, where the
+// attributes are sent as Kafka headers and the data as the message value.
+type Event struct {
+	ID          string
+	Type        string
+	Source      string
+	Subject     string
+	Time        time.Time
+	ContentType string
+	Data        []byte
+}
+
+// Validate checks that the required CloudEvents attributes are set.
+func (e *Event) Validate() error {
+	if e.ID == "" {
+		return errors.New("event id is required")
+	}
+	if e.Type == "" {
+		return errors.New("event type is required")
+	}
+	if e.Source == "" {
+		return errors.New("event source is required")
+	}
+	return nil
+}
+
+// Headers returns the Kafka headers carrying the event attributes.
+func (e *Event) Headers() []sarama.RecordHeader {
+	headers := []sarama.RecordHeader{
+		{Key: []byte(HeaderSpecVersion), Value: []byte(SpecVersion)},
+		{Key: []byte(HeaderID), Value: []byte(e.ID)},
+		{Key: []byte(HeaderType), Value: []byte(e.Type)},
+		{Key: []byte(HeaderSource), Value: []byte(e.Source)},
+	}
+	if e.Subject != "" {
+		headers = append(headers, sarama.RecordHeader{
+			Key: []byte(HeaderSubject), Value: []byte(e.Subject)})
+	}
+	if !e.Time.IsZero() {
+		headers = append(headers, sarama.RecordHeader{
+			Key: []byte(HeaderTime), Value: []byte(e.Time.UTC().Format(time.RFC3339Nano))})
+	}
+	if e.ContentType != "" {
+		headers = append(headers, sarama.RecordHeader{
+			Key: []byte(HeaderContentType), Value: []byte(e.ContentType)})
+	}
+	return headers
+}
+
+// EventFromMessage reads the CloudEvent carried by a consumed Kafka message.
+func EventFromMessage(msg *sarama.ConsumerMessage) (*Event, error) {
+	event := &Event{Data: msg.Value}
+	var specVersion string
+	for _, h := range msg.Headers {
+		if h == nil {
+			continue
+		}
+		value := string(h.Value)
+		switch string(h.Key) {
+		case HeaderSpecVersion:
+			specVersion = value
+		case HeaderID:
+			event.ID = value
+		case HeaderType:
+			event.Type = value
+		case HeaderSource:
+			event.Source = value
+		case HeaderSubject:
+			event.Subject = value
+		case HeaderContentType:
+			event.ContentType = value
+		case HeaderTime:
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s header: %w", HeaderTime, err)
+			}
+			event.Time = t
+		}
+	}
+
+	if specVersion != SpecVersion {
+		return nil, fmt.Errorf("unsupported cloudevents spec version %q", specVersion)
+	}
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
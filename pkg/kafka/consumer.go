@@ -2,25 +2,53 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"payment-system/pkg/logger"
+	"payment-system/pkg/retry"
+	"time"
 
 	"github.com/IBM/sarama"
 )
 
 const consumerPrefix = "consumer-"
 
+// Backoff between attempts to process a message that failed with a
+// transient error.
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultMaxRetryBackoff = 30 * time.Second
+)
+
+// ErrPermanent marks handler errors that retrying cannot fix, such as
+// messages that cannot be decoded. Messages failing with it are logged and
+// skipped instead of retried.
+var ErrPermanent = errors.New("permanent error")
+
+// Permanent wraps err so the consumer skips the message instead of retrying
+// it.
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
 // ConsumerHandler defines interface to process messages.
 type ConsumerHandler interface {
 	ConsumeMessage(msg *sarama.ConsumerMessage) error
 }
 
-// Consumer wraps a Sarama ConsumerGroup.
+// Consumer wraps a Sarama ConsumerGroup. A message is only marked as
+// consumed once the handler succeeds: transient errors are retried with
+// backoff, so the offset never moves past a message that was not
+// processed. If the session ends while retrying, the message is left
+// unmarked and redelivered to the next owner of the partition.
 type Consumer struct {
 	group   sarama.ConsumerGroup
 	topics  []string
 	handler ConsumerHandler
 	logger  logger.Logger
+
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
 }
 
 // NewConsumer creates a Kafka consumer.
@@ -35,10 +63,12 @@ func NewConsumer(brokers []string, groupID string, topics []string,
 		return nil, err
 	}
 	return &Consumer{
-		group:   group,
-		topics:  topics,
-		handler: handler,
-		logger:  log,
+		group:           group,
+		topics:          topics,
+		handler:         handler,
+		logger:          log,
+		retryBackoff:    defaultRetryBackoff,
+		maxRetryBackoff: defaultMaxRetryBackoff,
 	}, nil
 }
 
@@ -70,20 +100,53 @@ func (c *Consumer) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
 
 func (c *Consumer) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for msg := range claim.Messages() {
-		if err := c.handler.ConsumeMessage(msg); err != nil {
-			c.logger.Error("failed to process message",
+		if err := c.process(sess.Context(), msg); err != nil {
+			// the session ended before the message was processed, leave it
+			// unmarked so it is consumed again.
+			c.logger.Warn("stopping claim with unprocessed message",
 				logger.String("topic", msg.Topic),
 				logger.Int("partition", int(msg.Partition)),
 				logger.Int("offset", int(msg.Offset)),
 				logger.Error(err))
-		} else {
+			return nil
+		}
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+// process handles the message, retrying transient errors until it succeeds
+// or ctx is done. Messages failing with ErrPermanent are skipped.
+func (c *Consumer) process(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	for attempt := 1; ; attempt++ {
+		err := c.handler.ConsumeMessage(msg)
+		if err == nil {
 			c.logger.Debug("message processed",
 				logger.String("topic", msg.Topic),
 				logger.Int("partition", int(msg.Partition)),
 				logger.Int("offset", int(msg.Offset)),
 			)
-			sess.MarkMessage(msg, "")
+			return nil
+		}
+		if errors.Is(err, ErrPermanent) {
+			c.logger.Error("skipping message that cannot be processed",
+				logger.String("topic", msg.Topic),
+				logger.Int("partition", int(msg.Partition)),
+				logger.Int("offset", int(msg.Offset)),
+				logger.Error(err))
+			return nil
+		}
+
+		c.logger.Error("failed to process message, retrying",
+			logger.String("topic", msg.Topic),
+			logger.Int("partition", int(msg.Partition)),
+			logger.Int("offset", int(msg.Offset)),
+			logger.Int("attempt", attempt),
+			logger.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry.Backoff(attempt, c.retryBackoff, c.maxRetryBackoff)):
 		}
 	}
-	return nil
 }
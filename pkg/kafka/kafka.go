@@ -24,6 +24,12 @@ func NewSaramaConfig(clientID string) *sarama.Config {
 	config.Producer.Retry.Max = 5
 	config.Producer.Retry.Backoff = 100 * time.Millisecond
 
+	// messages with the same key always go to the same partition, and retries
+	// never reorder messages within a partition.
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	config.Producer.Idempotent = true
+	config.Net.MaxOpenRequests = 1
+
 	// consumer settings.
 	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
 	config.Consumer.Offsets.Initial = sarama.OffsetOldest
@@ -1,7 +1,10 @@
 package kafka
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"payment-system/pkg/logger"
 
@@ -79,3 +82,224 @@ func TestConsumerHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestNewSaramaConfig_Ordering verifies that the producer settings keep
+// messages with the same key in order on the same partition.
+func TestNewSaramaConfig_Ordering(t *testing.T) {
+	config := NewSaramaConfig("test-client")
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !config.Producer.Idempotent {
+		t.Fatal("expected idempotent producer")
+	}
+	if config.Net.MaxOpenRequests != 1 {
+		t.Fatalf("expected 1 max open request, got %d", config.Net.MaxOpenRequests)
+	}
+
+	partitioner := config.Producer.Partitioner("test-topic")
+	msg := &sarama.ProducerMessage{Topic: "test-topic", Key: sarama.StringEncoder("aggregate-id")}
+	first, err := partitioner.Partition(msg, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		partition, err := partitioner.Partition(msg, 12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if partition != first {
+			t.Fatalf("expected partition %d, got %d", first, partition)
+		}
+	}
+}
+
+// TestProducer_SendEvent verifies that the Producer sends the CloudEvent
+// attributes as Kafka headers and the data as the message value.
+func TestProducer_SendEvent(t *testing.T) {
+	mockProducer := &MockSyncProducer{}
+	p := &Producer{
+		syncProducer: mockProducer,
+		logger:       &logger.LoopLogger{},
+	}
+
+	event := &Event{
+		ID:          "42",
+		Type:        "payment_created",
+		Source:      "/payment",
+		Time:        time.Date(2025, time.January, 2, 3, 4, 5, 0, time.UTC),
+		ContentType: ContentTypeJSON,
+		Data:        []byte(`{}`),
+	}
+	if err := p.SendEvent("test-topic", []byte("key"), event); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mockProducer.Messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(mockProducer.Messages))
+	}
+	msg := mockProducer.Messages[0]
+	headers := make(map[string]string)
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+
+	expected := map[string]string{
+		HeaderSpecVersion: "1.0",
+		HeaderID:          "42",
+		HeaderType:        "payment_created",
+		HeaderSource:      "/payment",
+		HeaderTime:        "2025-01-02T03:04:05Z",
+		HeaderContentType: "application/json",
+	}
+	for k, v := range expected {
+		if headers[k] != v {
+			t.Fatalf("expected header %s=%q, got %q", k, v, headers[k])
+		}
+	}
+
+	// the consumer side reads back the same event.
+	consumed := &sarama.ConsumerMessage{Value: []byte(`{}`)}
+	for i := range msg.Headers {
+		consumed.Headers = append(consumed.Headers, &msg.Headers[i])
+	}
+	got, err := EventFromMessage(consumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != event.ID || got.Type != event.Type || !got.Time.Equal(event.Time) {
+		t.Fatalf("unexpected event read from message: %+v", got)
+	}
+}
+
+// TestProducer_SendEventInvalid verifies that events without the required
+// attributes are rejected.
+func TestProducer_SendEventInvalid(t *testing.T) {
+	mockProducer := &MockSyncProducer{}
+	p := &Producer{
+		syncProducer: mockProducer,
+		logger:       &logger.LoopLogger{},
+	}
+
+	if err := p.SendEvent("test-topic", nil, &Event{ID: "1"}); err == nil {
+		t.Fatal("expected error for event without type and source")
+	}
+	if len(mockProducer.Messages) != 0 {
+		t.Fatalf("expected no message, got %d", len(mockProducer.Messages))
+	}
+}
+
+// mockSession records the messages marked as consumed.
+type mockSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+}
+
+func (s *mockSession) Context() context.Context { return s.ctx }
+
+func (s *mockSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+// mockClaim delivers the given messages.
+type mockClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *mockClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func newMockClaim(offsets ...int64) *mockClaim {
+	claim := &mockClaim{messages: make(chan *sarama.ConsumerMessage, len(offsets))}
+	for _, offset := range offsets {
+		claim.messages <- &sarama.ConsumerMessage{Topic: "test-topic", Offset: offset}
+	}
+	close(claim.messages)
+	return claim
+}
+
+// funcHandler adapts a function to ConsumerHandler.
+type funcHandler func(msg *sarama.ConsumerMessage) error
+
+func (f funcHandler) ConsumeMessage(msg *sarama.ConsumerMessage) error { return f(msg) }
+
+func newTestConsumer(handler ConsumerHandler) *Consumer {
+	return &Consumer{
+		handler:         handler,
+		logger:          &logger.LoopLogger{},
+		retryBackoff:    time.Millisecond,
+		maxRetryBackoff: time.Millisecond,
+	}
+}
+
+// TestConsumeClaim_RetriesTransientErrors verifies that a message failing
+// with a transient error is consumed again before its offset is marked, so
+// a later message never commits past it.
+func TestConsumeClaim_RetriesTransientErrors(t *testing.T) {
+	attempts := make(map[int64]int)
+	c := newTestConsumer(funcHandler(func(msg *sarama.ConsumerMessage) error {
+		attempts[msg.Offset]++
+		if msg.Offset == 1 && attempts[msg.Offset] < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+	sess := &mockSession{ctx: context.Background()}
+
+	if err := c.ConsumeClaim(sess, newMockClaim(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if attempts[1] != 3 || attempts[2] != 1 {
+		t.Fatalf("unexpected attempts: %v", attempts)
+	}
+	if len(sess.marked) != 2 || sess.marked[0] != 1 || sess.marked[1] != 2 {
+		t.Fatalf("unexpected marked offsets: %v", sess.marked)
+	}
+}
+
+// TestConsumeClaim_StopsUnmarkedWhenSessionEnds verifies that a message still
+// failing when the session ends is left unmarked, together with the
+// messages after it, so it is redelivered.
+func TestConsumeClaim_StopsUnmarkedWhenSessionEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled []int64
+	c := newTestConsumer(funcHandler(func(msg *sarama.ConsumerMessage) error {
+		handled = append(handled, msg.Offset)
+		cancel()
+		return errors.New("connection refused")
+	}))
+	sess := &mockSession{ctx: ctx}
+
+	if err := c.ConsumeClaim(sess, newMockClaim(1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.marked) != 0 {
+		t.Fatalf("expected no marked offsets, got %v", sess.marked)
+	}
+	if len(handled) != 1 || handled[0] != 1 {
+		t.Fatalf("expected only offset 1 to be handled, got %v", handled)
+	}
+}
+
+// TestConsumeClaim_SkipsPermanentErrors verifies that a message that cannot
+// be processed is marked without retrying it.
+func TestConsumeClaim_SkipsPermanentErrors(t *testing.T) {
+	attempts := 0
+	c := newTestConsumer(funcHandler(func(msg *sarama.ConsumerMessage) error {
+		attempts++
+		return Permanent(errors.New("unknown event type"))
+	}))
+	sess := &mockSession{ctx: context.Background()}
+
+	if err := c.ConsumeClaim(sess, newMockClaim(1)); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d", attempts)
+	}
+	if len(sess.marked) != 1 {
+		t.Fatalf("expected the message to be marked, got %v", sess.marked)
+	}
+}
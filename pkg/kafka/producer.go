@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"fmt"
 	"payment-system/pkg/logger"
 
 	"github.com/IBM/sarama"
@@ -25,7 +26,7 @@ func NewProducer(brokers []string, clientID string,
 	if err != nil {
 		return nil, err
 	}
-	return &Producer{syncProducer: producer}, nil
+	return &Producer{syncProducer: producer, logger: logger}, nil
 }
 
 // SendMessage sends a message to Kafka topic with retries.
@@ -38,7 +39,34 @@ func (p *Producer) SendMessage(topic string, key, value []byte) error {
 
 	_, _, err := p.syncProducer.SendMessage(msg)
 	if err != nil {
-		p.logger.Error("failed to send message to topic %s: %v", topic, err)
+		p.logger.Error("failed to send message",
+			logger.String("topic", topic),
+			logger.Error(err))
+	}
+
+	return err
+}
+
+// SendEvent sends a CloudEvent to Kafka topic following the CloudEvents Kafka
+// protocol binding in binary content mode.
+func (p *Producer) SendEvent(topic string, key []byte, event *Event) error {
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic:   topic,
+		Key:     sarama.ByteEncoder(key),
+		Value:   sarama.ByteEncoder(event.Data),
+		Headers: event.Headers(),
+	}
+
+	_, _, err := p.syncProducer.SendMessage(msg)
+	if err != nil {
+		p.logger.Error("failed to send event",
+			logger.String("topic", topic),
+			logger.String("event_id", event.ID),
+			logger.Error(err))
 	}
 
 	return err
@@ -0,0 +1,230 @@
+// Package money represents monetary amounts as exact decimals together with
+// their ISO 4217 currency.
+//
+// Amounts are always rounded to the minor units of their currency, half away
+// from zero, and arithmetic between different currencies fails with
+// ErrCurrencyMismatch instead of mixing them.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrCurrencyMismatch is returned when operating on amounts of different
+	// currencies.
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrUnknownCurrency is returned for currency codes without minor units
+	// defined.
+	ErrUnknownCurrency = errors.New("unknown currency")
+	// ErrTooPrecise is returned when parsing an amount with more decimals
+	// than the minor units of its currency.
+	ErrTooPrecise = errors.New("amount has more decimals than the currency allows")
+)
+
+// Amount is an exact decimal amount without currency. It implements
+// sql.Scanner, so it can read NUMERIC columns to be combined with their
+// currency through New.
+type Amount = decimal.Decimal
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+// minorUnits holds the number of decimal places of the supported currencies.
+var minorUnits = map[Currency]int32{
+	"ARS": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CLP": 0,
+	"CNY": 2, "COP": 2, "EUR": 2, "GBP": 2, "JOD": 3, "JPY": 0, "KRW": 0,
+	"KWD": 3, "MXN": 2, "OMR": 3, "PEN": 2, "USD": 2, "UYU": 2,
+}
+
+// ParseCurrency returns the currency with the given code. Codes are
+// case-insensitive and surrounding spaces, such as the padding of CHAR
+// columns, are ignored.
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if _, ok := minorUnits[c]; !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// MinorUnits returns the number of decimal places of the currency.
+func (c Currency) MinorUnits() int32 {
+	return minorUnits[c]
+}
+
+// Money is an amount in a currency. The zero value has no currency and is
+// only useful as a placeholder.
+type Money struct {
+	amount   decimal.Decimal
+	currency Currency
+}
+
+// New returns the given amount in the given currency, rounded to its minor
+// units. Use Parse for amounts that must not be rounded, such as user input.
+func New(amount decimal.Decimal, currency string) (Money, error) {
+	c, err := ParseCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{amount: amount.Round(c.MinorUnits()), currency: c}, nil
+}
+
+// Parse returns the decimal amount in the given currency. Unlike New, it
+// returns ErrTooPrecise instead of rounding amounts with more decimals than
+// the minor units of the currency; trailing zeros are allowed.
+func Parse(amount, currency string) (Money, error) {
+	d, err := decimal.NewFromString(amount)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+	c, err := ParseCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if !d.Equal(d.Round(c.MinorUnits())) {
+		return Money{}, fmt.Errorf("%w: %s %s has more than %d decimals", ErrTooPrecise,
+			amount, c, c.MinorUnits())
+	}
+	return Money{amount: d.Round(c.MinorUnits()), currency: c}, nil
+}
+
+// MustParse is like Parse but panics on error. It is meant for constants and
+// tests.
+func MustParse(amount, currency string) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Zero returns a zero amount in the given currency.
+func Zero(currency Currency) Money {
+	return Money{amount: decimal.Zero, currency: currency}
+}
+
+// Amount returns the decimal amount.
+func (m Money) Amount() decimal.Decimal {
+	return m.amount
+}
+
+// Currency returns the currency of the amount.
+func (m Money) Currency() Currency {
+	return m.currency
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.amount.IsZero()
+}
+
+// IsPositive reports whether the amount is greater than zero.
+func (m Money) IsPositive() bool {
+	return m.amount.IsPositive()
+}
+
+// IsNegative reports whether the amount is less than zero.
+func (m Money) IsNegative() bool {
+	return m.amount.IsNegative()
+}
+
+// Add returns the sum of both amounts.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return Money{amount: m.amount.Add(o.amount), currency: m.currency}, nil
+}
+
+// Sub returns the difference of both amounts.
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return Money{amount: m.amount.Sub(o.amount), currency: m.currency}, nil
+}
+
+// Mul returns the amount multiplied by the given factor, rounded to the minor
+// units of the currency.
+func (m Money) Mul(factor decimal.Decimal) Money {
+	return Money{
+		amount:   m.amount.Mul(factor).Round(m.currency.MinorUnits()),
+		currency: m.currency,
+	}
+}
+
+// Neg returns the amount with the opposite sign.
+func (m Money) Neg() Money {
+	return Money{amount: m.amount.Neg(), currency: m.currency}
+}
+
+// Cmp compares both amounts, returning -1, 0 or +1 when the amount is less
+// than, equal to or greater than the other.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	return m.amount.Cmp(o.amount), nil
+}
+
+// Equal reports whether both amounts have the same value and currency.
+func (m Money) Equal(o Money) bool {
+	return m.currency == o.currency && m.amount.Equal(o.amount)
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.currency != o.currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	return nil
+}
+
+// AmountString returns the amount with exactly the minor units of its
+// currency, e.g. "10.50".
+func (m Money) AmountString() string {
+	return m.amount.StringFixed(m.currency.MinorUnits())
+}
+
+// String returns the amount followed by its currency, e.g. "10.50 USD".
+func (m Money) String() string {
+	return m.AmountString() + " " + string(m.currency)
+}
+
+// jsonMoney is the JSON representation of Money. The amount is a string so it
+// is not read as a float by clients.
+type jsonMoney struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as {"amount":"10.50","currency":"USD"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.AmountString(), Currency: string(m.currency)})
+}
+
+// UnmarshalJSON decodes an amount encoded by MarshalJSON.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v jsonMoney
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := Parse(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, storing the amount in NUMERIC columns. The
+// currency is stored separately.
+func (m Money) Value() (driver.Value, error) {
+	return m.AmountString(), nil
+}
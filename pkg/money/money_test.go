@@ -0,0 +1,121 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/test-go/testify/require"
+)
+
+// TestNew_Rounding checks that amounts are rounded to the minor units of
+// their currency.
+func TestNew_Rounding(t *testing.T) {
+	tests := []struct {
+		amount, currency, want string
+	}{
+		{"10.005", "USD", "10.01"},
+		{"10.004", "usd", "10.00"},
+		{"-10.005", "EUR", "-10.01"},
+		{"1234.5", "JPY", "1235"},
+		{"1.2345", "KWD", "1.235"},
+		{"0.1", " CLP ", "0"},
+	}
+	for _, tt := range tests {
+		m, err := New(decimal.RequireFromString(tt.amount), tt.currency)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, m.AmountString(), tt.amount+" "+tt.currency)
+	}
+
+	_, err := New(decimal.NewFromInt(1), "XXX")
+	require.True(t, errors.Is(err, ErrUnknownCurrency))
+}
+
+// TestParse checks that parsed amounts keep their value and that amounts
+// with more decimals than the currency allows are rejected, not rounded.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount, currency, want string
+	}{
+		{"10.5", "usd", "10.50"},
+		{"10.500", "USD", "10.50"},
+		{"-10.01", "EUR", "-10.01"},
+		{"1234", "JPY", "1234"},
+		{"1.234", "KWD", "1.234"},
+		{"3", " CLP ", "3"},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.amount, tt.currency)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, m.AmountString(), tt.amount+" "+tt.currency)
+	}
+
+	for _, tt := range []struct{ amount, currency string }{
+		{"10.005", "USD"},
+		{"0.004", "USD"},
+		{"1234.5", "JPY"},
+		{"1.2345", "KWD"},
+	} {
+		_, err := Parse(tt.amount, tt.currency)
+		require.True(t, errors.Is(err, ErrTooPrecise), tt.amount+" "+tt.currency)
+	}
+
+	_, err := Parse("1.00", "XXX")
+	require.True(t, errors.Is(err, ErrUnknownCurrency))
+	_, err = Parse("one", "USD")
+	require.Error(t, err)
+}
+
+// TestArithmetic checks exact arithmetic and that currencies are never mixed.
+func TestArithmetic(t *testing.T) {
+	a := MustParse("0.10", "USD")
+	b := MustParse("0.20", "USD")
+
+	sum, err := a.Add(b)
+	require.NoError(t, err)
+	require.True(t, sum.Equal(MustParse("0.30", "USD")))
+
+	diff, err := a.Sub(b)
+	require.NoError(t, err)
+	require.True(t, diff.IsNegative())
+	require.True(t, diff.Neg().Equal(a))
+
+	cmp, err := b.Cmp(a)
+	require.NoError(t, err)
+	require.Equal(t, 1, cmp)
+
+	require.Equal(t, "0.03", a.Mul(decimal.RequireFromString("0.333")).AmountString())
+
+	eur := MustParse("0.10", "EUR")
+	_, err = a.Add(eur)
+	require.True(t, errors.Is(err, ErrCurrencyMismatch))
+	_, err = a.Sub(eur)
+	require.True(t, errors.Is(err, ErrCurrencyMismatch))
+	_, err = a.Cmp(eur)
+	require.True(t, errors.Is(err, ErrCurrencyMismatch))
+	require.False(t, a.Equal(eur))
+}
+
+// TestJSON checks that amounts round trip through JSON as strings.
+func TestJSON(t *testing.T) {
+	m := MustParse("99.9", "USD")
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"amount":"99.90","currency":"USD"}`, string(data))
+
+	var decoded Money
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.True(t, m.Equal(decoded))
+
+	require.Error(t, json.Unmarshal([]byte(`{"amount":"1","currency":"XXX"}`), &decoded))
+}
+
+// TestValue checks the value stored in NUMERIC columns.
+func TestValue(t *testing.T) {
+	v, err := MustParse("5", "USD").Value()
+	require.NoError(t, err)
+	require.Equal(t, "5.00", v)
+	require.Equal(t, "5.00 USD", MustParse("5", "USD").String())
+}
@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"payment-system/pkg/db"
+	"payment-system/pkg/domain"
+	"payment-system/pkg/logger"
+	"time"
+)
+
+// JanitorConfig holds the outbox janitor settings.
+type JanitorConfig struct {
+	// Interval is the time between two janitor runs.
+	Interval time.Duration
+	// Retention is how long SENT events are kept in the outbox before being
+	// moved to the archive.
+	Retention time.Duration
+	// DeadRetention is how long DEAD events are kept for review before being
+	// deleted.
+	DeadRetention time.Duration
+	// BatchSize is the maximum number of rows moved or deleted per transaction.
+	BatchSize int
+	// DryRun reports what would be archived or purged without modifying rows.
+	DryRun bool
+}
+
+// JanitorResult holds the number of outbox rows handled by a janitor run.
+type JanitorResult struct {
+	Archived int64
+	Purged   int64
+}
+
+// archiveCandidate is a SENT outbox row selected to be archived.
+type archiveCandidate struct {
+	ID        int64     `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Janitor archives published outbox events into the monthly partitioned
+// outbox_archive table and purges DEAD events, keeping the outbox table small.
+type Janitor struct {
+	db     db.DB
+	logger logger.Logger
+	cfg    JanitorConfig
+	opts   options
+}
+
+// NewJanitor creates a new Janitor with the given database, logger and
+// configuration.
+func NewJanitor(db db.DB, logger logger.Logger, cfg JanitorConfig, opts ...Option) *Janitor {
+	cfg.BatchSize = max(cfg.BatchSize, 1)
+	return &Janitor{
+		db:     db,
+		logger: logger,
+		cfg:    cfg,
+		opts:   newOptions(opts),
+	}
+}
+
+// Start runs the janitor every interval until the provided context is canceled.
+func (j *Janitor) Start(ctx context.Context) {
+	j.logger.Info("starting outbox janitor")
+
+	for {
+		if _, err := j.Run(ctx); err != nil && ctx.Err() == nil {
+			j.logger.Error("failed to clean up outbox", logger.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			j.logger.Info("outbox janitor stopped due to context cancellation")
+			return
+		case <-time.After(j.cfg.Interval):
+		}
+	}
+}
+
+// Run archives SENT events older than the retention and deletes DEAD events
+// older than the dead retention. In dry-run mode it only counts them.
+func (j *Janitor) Run(ctx context.Context) (JanitorResult, error) {
+	var result JanitorResult
+	now := time.Now()
+	sentCutoff := now.Add(-j.cfg.Retention)
+	deadCutoff := now.Add(-j.cfg.DeadRetention)
+
+	if j.cfg.DryRun {
+		query := fmt.Sprintf(`SELECT count(*) FROM %s WHERE status = $1 AND sent_at < $2`,
+			j.opts.table("outbox"))
+		if err := j.db.QueryRow(ctx, &result.Archived, query,
+			domain.OutboxStatusSent, sentCutoff); err != nil {
+			return result, fmt.Errorf("count archivable outbox: %w", err)
+		}
+		query = fmt.Sprintf(`SELECT count(*) FROM %s WHERE status = $1 AND updated_at < $2`,
+			j.opts.table("outbox"))
+		if err := j.db.QueryRow(ctx, &result.Purged, query,
+			domain.OutboxStatusDead, deadCutoff); err != nil {
+			return result, fmt.Errorf("count purgeable outbox: %w", err)
+		}
+
+		j.logger.Info("outbox janitor dry run",
+			logger.Int("archivable", int(result.Archived)),
+			logger.Int("purgeable", int(result.Purged)))
+		return result, nil
+	}
+
+	for ctx.Err() == nil {
+		n, err := j.archiveBatch(ctx, sentCutoff)
+		result.Archived += int64(n)
+		if err != nil {
+			return result, err
+		}
+		if n < j.cfg.BatchSize {
+			break
+		}
+	}
+
+	for ctx.Err() == nil {
+		n, err := j.purgeBatch(ctx, deadCutoff)
+		result.Purged += n
+		if err != nil {
+			return result, err
+		}
+		if n < int64(j.cfg.BatchSize) {
+			break
+		}
+	}
+
+	j.logger.Info("outbox janitor run completed",
+		logger.Int("archived", int(result.Archived)),
+		logger.Int("purged", int(result.Purged)))
+	return result, ctx.Err()
+}
+
+// archiveBatch moves a batch of SENT events sent before the cutoff from the
+// outbox to the archive within a single transaction, and returns the number
+// of events moved.
+func (j *Janitor) archiveBatch(ctx context.Context, cutoff time.Time) (int, error) {
+	tx, err := j.db.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+
+	var candidates []archiveCandidate
+	query := fmt.Sprintf(`
+		SELECT id, created_at
+		FROM %s
+		WHERE status = $1 AND sent_at < $2
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`, j.opts.table("outbox"))
+	if err := tx.Select(ctx, &candidates, query,
+		domain.OutboxStatusSent, cutoff, j.cfg.BatchSize); err != nil {
+		_ = tx.Rollback(ctx)
+		return 0, fmt.Errorf("select archivable outbox: %w", err)
+	}
+	if len(candidates) == 0 {
+		_ = tx.Rollback(ctx)
+		return 0, nil
+	}
+
+	// make sure a partition exists for every month in the batch.
+	ids := make([]int64, 0, len(candidates))
+	months := make(map[time.Time]struct{})
+	for _, c := range candidates {
+		ids = append(ids, c.ID)
+		months[monthOf(c.CreatedAt)] = struct{}{}
+	}
+	for month := range months {
+		if _, err := tx.Exec(ctx, j.partitionDDL(month)); err != nil {
+			_ = tx.Rollback(ctx)
+			return 0, fmt.Errorf("create archive partition: %w", err)
+		}
+	}
+
+	move := fmt.Sprintf(`
+		WITH moved AS (
+			DELETE FROM %s
+			WHERE id = ANY($1)
+			RETURNING id, aggregate_id, aggregate_type, event_type, payload, content_type,
+				status, attempts, last_error, created_at, updated_at, sent_at
+		)
+		INSERT INTO %s
+		(id, aggregate_id, aggregate_type, event_type, payload, content_type,
+			status, attempts, last_error, created_at, updated_at, sent_at)
+		SELECT * FROM moved
+	`, j.opts.table("outbox"), j.opts.table("outbox_archive"))
+	n, err := tx.Exec(ctx, move, ids)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return 0, fmt.Errorf("archive outbox: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return int(n), nil
+}
+
+// purgeBatch deletes a batch of DEAD events last updated before the cutoff
+// and returns the number of events deleted.
+func (j *Janitor) purgeBatch(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := fmt.Sprintf(`
+		DELETE FROM %[1]s
+		WHERE id IN (
+			SELECT id
+			FROM %[1]s
+			WHERE status = $1 AND updated_at < $2
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+	`, j.opts.table("outbox"))
+	n, err := j.db.Exec(ctx, query, domain.OutboxStatusDead, cutoff, j.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("purge dead outbox: %w", err)
+	}
+	return n, nil
+}
+
+// monthOf returns the first instant of the UTC month containing t.
+func monthOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionDDL returns the statement creating the archive partition for the
+// month starting at the given time, if it does not exist yet.
+func (j *Janitor) partitionDDL(month time.Time) string {
+	partition := fmt.Sprintf("outbox_archive_%04d_%02d", month.Year(), int(month.Month()))
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s
+		PARTITION OF %s
+		FOR VALUES FROM ('%s') TO ('%s')
+	`, j.opts.table(partition), j.opts.table("outbox_archive"),
+		month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339))
+}
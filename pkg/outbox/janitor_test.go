@@ -0,0 +1,65 @@
+package outbox
+
+import (
+	"context"
+	"payment-system/pkg/db"
+	"payment-system/pkg/logger"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/test-go/testify/require"
+)
+
+type MockCountDB struct {
+	db.DB
+	Counts  []int64
+	Queries []string
+}
+
+func (m *MockCountDB) QueryRow(ctx context.Context, dest any, query string, args ...any) error {
+	m.Queries = append(m.Queries, query)
+	*dest.(*int64) = m.Counts[0]
+	m.Counts = m.Counts[1:]
+	return nil
+}
+
+func (m *MockCountDB) BeginTx(ctx context.Context) (db.Tx, error) {
+	panic("dry run must not open transactions")
+}
+
+func (m *MockCountDB) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	panic("dry run must not modify rows")
+}
+
+// TestRun_DryRun checks that a dry run only counts the archivable and
+// purgeable events without modifying the outbox.
+func TestRun_DryRun(t *testing.T) {
+	mockDB := &MockCountDB{Counts: []int64{12, 3}}
+	j := NewJanitor(mockDB, logger.NewNoopLogger(), JanitorConfig{
+		Retention:     time.Hour,
+		DeadRetention: time.Hour,
+		BatchSize:     100,
+		DryRun:        true,
+	}, WithSchema("payment"))
+
+	result, err := j.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, JanitorResult{Archived: 12, Purged: 3}, result)
+	require.Len(t, mockDB.Queries, 2)
+	require.True(t, strings.Contains(mockDB.Queries[0], `"payment"."outbox"`))
+}
+
+// TestPartitionDDL checks that archive partitions cover a whole UTC month.
+func TestPartitionDDL(t *testing.T) {
+	created := time.Date(2025, time.December, 31, 23, 30, 0, 0, time.FixedZone("UTC-3", -3*3600))
+
+	month := monthOf(created)
+	require.Equal(t, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), month)
+
+	j := NewJanitor(nil, logger.NewNoopLogger(), JanitorConfig{}, WithSchema("payment"))
+	ddl := j.partitionDDL(month)
+	require.True(t, strings.Contains(ddl, `"payment"."outbox_archive_2026_01"`))
+	require.True(t, strings.Contains(ddl, `PARTITION OF "payment"."outbox_archive"`))
+	require.True(t, strings.Contains(ddl, "FROM ('2026-01-01T00:00:00Z') TO ('2026-02-01T00:00:00Z')"))
+}
@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"payment-system/pkg/db"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Migration is a SQL migration creating or updating the outbox tables.
+type Migration struct {
+	Name string
+	SQL  string
+}
+
+// migrationData holds the values available to the migration templates.
+type migrationData struct {
+	// Schema is the quoted schema identifier and SchemaName the schema as a
+	// string literal, to compare against catalog columns.
+	Schema     string
+	SchemaName string
+	Channel    string
+}
+
+// Migrations returns the SQL migrations for the outbox of the configured
+// schema, in the order they must be applied.
+func Migrations(opts ...Option) ([]Migration, error) {
+	o := newOptions(opts)
+	data := migrationData{
+		Schema:     pgx.Identifier{o.schema}.Sanitize(),
+		SchemaName: quoteLiteral(o.schema),
+		Channel:    quoteLiteral(o.channel()),
+	}
+
+	names, err := fs.Glob(migrationFS, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("list outbox migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		tmpl, err := template.ParseFS(migrationFS, name)
+		if err != nil {
+			return nil, fmt.Errorf("parse outbox migration %s: %w", name, err)
+		}
+
+		var sql bytes.Buffer
+		if err := tmpl.Execute(&sql, data); err != nil {
+			return nil, fmt.Errorf("render outbox migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{
+			Name: strings.TrimPrefix(name, "migrations/"),
+			SQL:  sql.String(),
+		})
+	}
+	return migrations, nil
+}
+
+// Migrate applies the migrations of the configured schema in a single
+// transaction. The migrations are idempotent, so services run Migrate on
+// every start; a transaction-level advisory lock keeps replicas starting
+// together from applying them concurrently. The schema is created if it does
+// not exist, and outbox tables created before the migrations are upgraded.
+func Migrate(ctx context.Context, conn db.DB, opts ...Option) error {
+	migrations, err := Migrations(opts...)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	if err := applyMigrations(ctx, tx, newOptions(opts).schema, migrations); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return nil
+}
+
+func applyMigrations(ctx context.Context, tx db.Tx, schema string,
+	migrations []Migration) error {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))",
+		"outbox_migrations:"+schema); err != nil {
+		return fmt.Errorf("lock outbox migrations: %w", err)
+	}
+	for _, m := range migrations {
+		if _, err := tx.Exec(ctx, m.SQL); err != nil {
+			return fmt.Errorf("apply outbox migration %s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// quoteLiteral returns s as a SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
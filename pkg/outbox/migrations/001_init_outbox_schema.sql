@@ -0,0 +1,43 @@
+CREATE SCHEMA IF NOT EXISTS {{.Schema}};
+
+CREATE TABLE IF NOT EXISTS {{.Schema}}.outbox (
+    id BIGSERIAL PRIMARY KEY,
+    aggregate_id UUID NOT NULL,
+    aggregate_type VARCHAR(50) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ
+);
+
+-- outboxes created before this package, such as the payment one, lack the
+-- columns added since.
+ALTER TABLE {{.Schema}}.outbox
+ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS last_error TEXT,
+ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+ADD COLUMN IF NOT EXISTS sent_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_status_created_at
+ON {{.Schema}}.outbox (status, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending_next_attempt_at
+ON {{.Schema}}.outbox (next_attempt_at)
+WHERE status = 'PENDING';
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending_aggregate_id
+ON {{.Schema}}.outbox (aggregate_id, id)
+WHERE status = 'PENDING';
+
+CREATE INDEX IF NOT EXISTS idx_outbox_sent_sent_at
+ON {{.Schema}}.outbox (sent_at)
+WHERE status = 'SENT';
+
+CREATE INDEX IF NOT EXISTS idx_outbox_dead_updated_at
+ON {{.Schema}}.outbox (updated_at)
+WHERE status = 'DEAD';
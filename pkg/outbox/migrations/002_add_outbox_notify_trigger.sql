@@ -0,0 +1,14 @@
+CREATE OR REPLACE FUNCTION {{.Schema}}.notify_outbox()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify({{.Channel}}, '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_outbox_notify ON {{.Schema}}.outbox;
+
+CREATE TRIGGER trg_outbox_notify
+AFTER INSERT ON {{.Schema}}.outbox
+FOR EACH STATEMENT
+EXECUTE FUNCTION {{.Schema}}.notify_outbox();
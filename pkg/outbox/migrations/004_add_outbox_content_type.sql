@@ -0,0 +1,31 @@
+-- event payloads are stored as raw bytes so they can hold JSON or Protobuf
+-- encoded events, as described by content_type. The payloads are only
+-- converted while they are still text, converting a bytea column again would
+-- corrupt them.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = {{.SchemaName}} AND table_name = 'outbox'
+            AND column_name = 'payload' AND data_type <> 'bytea'
+    ) THEN
+        ALTER TABLE {{.Schema}}.outbox
+        ALTER COLUMN payload TYPE BYTEA USING convert_to(payload::text, 'UTF8');
+    END IF;
+
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = {{.SchemaName}} AND table_name = 'outbox_archive'
+            AND column_name = 'payload' AND data_type <> 'bytea'
+    ) THEN
+        ALTER TABLE {{.Schema}}.outbox_archive
+        ALTER COLUMN payload TYPE BYTEA USING convert_to(payload::text, 'UTF8');
+    END IF;
+END
+$$;
+
+ALTER TABLE {{.Schema}}.outbox
+ADD COLUMN IF NOT EXISTS content_type VARCHAR(100) NOT NULL DEFAULT 'application/json';
+
+ALTER TABLE {{.Schema}}.outbox_archive
+ADD COLUMN IF NOT EXISTS content_type VARCHAR(100) NOT NULL DEFAULT 'application/json';
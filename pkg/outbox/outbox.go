@@ -0,0 +1,102 @@
+// Package outbox implements the transactional outbox pattern on top of pkg/db.
+//
+// Services write events with Enqueue in the same transaction as their state
+// changes. A Relayer publishes pending events to Kafka and a Janitor archives
+// and purges them afterwards. All of them operate on the outbox table of the
+// schema selected with WithSchema, created by Migrate or by applying the SQL
+// returned by Migrations.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"payment-system/pkg/db"
+	"payment-system/pkg/domain"
+	"payment-system/pkg/kafka"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultSchema is the schema used when no schema option is given.
+const defaultSchema = "public"
+
+// options holds the settings shared by the outbox components.
+type options struct {
+	schema string
+}
+
+// Option configures the outbox components.
+type Option func(*options)
+
+// WithSchema selects the database schema holding the outbox tables.
+func WithSchema(schema string) Option {
+	return func(o *options) {
+		o.schema = schema
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{schema: defaultSchema}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// table returns the quoted, schema-qualified name of the given table.
+func (o options) table(name string) string {
+	return pgx.Identifier{o.schema, name}.Sanitize()
+}
+
+// channel returns the channel notified when events are enqueued.
+func (o options) channel() string {
+	return o.schema + "_outbox"
+}
+
+// Channel returns the channel notified when events are enqueued in the outbox
+// of the configured schema.
+func Channel(opts ...Option) string {
+	return newOptions(opts).channel()
+}
+
+// Enqueue inserts the given event as PENDING in the outbox within the given
+// transaction, so it is only published if the transaction commits. Events
+// without content type are stored as JSON. The ID, status and timestamps of
+// the event are set on success.
+func Enqueue(ctx context.Context, tx db.Tx, event *domain.Outbox, opts ...Option) error {
+	o := newOptions(opts)
+
+	if event.ContentType == "" {
+		event.ContentType = kafka.ContentTypeJSON
+	}
+
+	now := time.Now()
+	insert := fmt.Sprintf(`
+		INSERT INTO %s
+		(aggregate_id, aggregate_type, event_type, payload, content_type, status,
+			created_at, updated_at, next_attempt_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$7,$7)
+		RETURNING id
+	`, o.table("outbox"))
+
+	var id int64
+	if err := tx.QueryRow(ctx, &id, insert,
+		event.AggregateID,
+		event.AggregateType,
+		event.EventType,
+		event.Payload,
+		event.ContentType,
+		domain.OutboxStatusPending,
+		now,
+	); err != nil {
+		return fmt.Errorf("insert outbox event: %w", err)
+	}
+
+	event.ID = id
+	event.Status = domain.OutboxStatusPending
+	event.CreatedAt = now
+	event.UpdatedAt = now
+	event.NextAttemptAt = now
+	return nil
+}
@@ -0,0 +1,77 @@
+package outbox
+
+import (
+	"context"
+	"payment-system/pkg/domain"
+	"payment-system/pkg/kafka"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+)
+
+// TestEnqueue checks that an event is inserted as pending in the outbox of
+// the configured schema and that its generated fields are set.
+func TestEnqueue(t *testing.T) {
+	tx := &MockTx{}
+	event := &domain.Outbox{
+		AggregateID:   uuid.New(),
+		AggregateType: "payment",
+		EventType:     "payment_created",
+		Payload:       []byte(`{}`),
+	}
+
+	err := Enqueue(context.Background(), tx, event, WithSchema("payment"))
+	require.NoError(t, err)
+
+	require.Len(t, tx.Queries, 1)
+	require.True(t, strings.Contains(tx.Queries[0], `INSERT INTO "payment"."outbox"`))
+	require.Equal(t, event.AggregateID, tx.Execs[0][0])
+	require.Equal(t, kafka.ContentTypeJSON, tx.Execs[0][4])
+	require.Equal(t, domain.OutboxStatusPending, tx.Execs[0][5])
+
+	require.Equal(t, int64(1), event.ID)
+	require.Equal(t, domain.OutboxStatusPending, event.Status)
+	require.False(t, event.CreatedAt.IsZero())
+}
+
+// TestMigrations checks that the migrations are rendered in order for the
+// configured schema and notify its channel.
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations(WithSchema("wallet"))
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	require.Equal(t, "001_init_outbox_schema.sql", migrations[0].Name)
+	for _, m := range migrations {
+		require.False(t, strings.Contains(m.SQL, "{{"))
+	}
+	require.True(t, strings.Contains(migrations[0].SQL, `CREATE TABLE IF NOT EXISTS "wallet".outbox`))
+	require.True(t, strings.Contains(migrations[0].SQL, `ADD COLUMN IF NOT EXISTS next_attempt_at`))
+	require.True(t, strings.Contains(migrations[1].SQL, `pg_notify('wallet_outbox', '')`))
+	require.Equal(t, "wallet_outbox", Channel(WithSchema("wallet")))
+
+	// the payload conversion only runs on text columns, so the migration can
+	// be applied again.
+	require.Equal(t, "004_add_outbox_content_type.sql", migrations[3].Name)
+	require.True(t, strings.Contains(migrations[3].SQL, `table_schema = 'wallet'`))
+	require.True(t, strings.Contains(migrations[3].SQL, `data_type <> 'bytea'`))
+}
+
+// TestMigrate checks that the migrations are applied in order in one
+// transaction, after taking the lock of the schema.
+func TestMigrate(t *testing.T) {
+	tx := &MockTx{}
+	require.NoError(t, Migrate(context.Background(), &MockDB{Tx: tx}, WithSchema("wallet")))
+
+	migrations, err := Migrations(WithSchema("wallet"))
+	require.NoError(t, err)
+	require.Len(t, tx.ExecQueries, len(migrations)+1)
+	require.True(t, strings.Contains(tx.ExecQueries[0], "pg_advisory_xact_lock"))
+	require.Equal(t, []any{"outbox_migrations:wallet"}, tx.Execs[0])
+	for i, m := range migrations {
+		require.Equal(t, m.SQL, tx.ExecQueries[i+1])
+	}
+	require.True(t, tx.Committed)
+}
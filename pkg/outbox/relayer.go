@@ -0,0 +1,381 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"payment-system/pkg/db"
+	"payment-system/pkg/domain"
+	"payment-system/pkg/kafka"
+	"payment-system/pkg/logger"
+	"payment-system/pkg/retry"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLastErrorLength bounds the error message stored in the outbox row.
+const maxLastErrorLength = 1024
+
+// Publisher defines the interface used to publish outbox events.
+type Publisher interface {
+	SendEvent(topic string, key []byte, event *kafka.Event) error
+}
+
+// RetryPolicy controls how failed outbox events are retried. The delay before
+// a retry grows from BaseBackoff up to MaxBackoff, see retry.Backoff. After
+// MaxAttempts failed publishes an event is moved to the DEAD status and no
+// longer retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// TopicFunc returns the Kafka topic an event type is published to.
+type TopicFunc func(eventType string) string
+
+// RelayerConfig holds the outbox relayer settings.
+type RelayerConfig struct {
+	// BatchSize is the maximum number of events selected per transaction.
+	BatchSize int
+	// Interval is the polling interval used when no notification is received.
+	Interval time.Duration
+	// MaxInFlight is the maximum number of concurrent publishes per batch.
+	MaxInFlight int
+	// Workers is the number of relayer goroutines processing the outbox.
+	Workers int
+	// Retry controls how failed events are retried.
+	Retry RetryPolicy
+	// Topic maps event types to topics. Defaults to DefaultTopic.
+	Topic TopicFunc
+	// Source is the CloudEvents source of the published events, identifying
+	// the service that produced them.
+	Source string
+}
+
+// Relayer is responsible for polling the outbox table, processing events,
+// and publishing them to Kafka.
+type Relayer struct {
+	db        db.DB
+	publisher Publisher
+	logger    logger.Logger
+	cfg       RelayerConfig
+	opts      options
+}
+
+// NewRelayer creates a new Relayer with the given database, Kafka producer,
+// logger and configuration. Non-positive sizes in the configuration default to one.
+func NewRelayer(db db.DB, publisher Publisher, logger logger.Logger,
+	cfg RelayerConfig, opts ...Option) *Relayer {
+	cfg.BatchSize = max(cfg.BatchSize, 1)
+	cfg.MaxInFlight = max(cfg.MaxInFlight, 1)
+	cfg.Workers = max(cfg.Workers, 1)
+	if cfg.Topic == nil {
+		cfg.Topic = DefaultTopic
+	}
+	return &Relayer{
+		db:        db,
+		publisher: publisher,
+		logger:    logger,
+		cfg:       cfg,
+		opts:      newOptions(opts),
+	}
+}
+
+// Start launches the configured number of relayer workers, which process
+// outbox events until the provided context is canceled, and blocks until all
+// of them and the outbox listener have stopped. Workers are woken up as soon as a new event is
+// notified on the outbox channel, and fall back to polling every interval in
+// case a notification is missed. Workers never pick the same event, as rows
+// are locked with FOR UPDATE SKIP LOCKED.
+func (r *Relayer) Start(ctx context.Context) {
+	r.logger.Info("starting outbox relayer",
+		logger.Int("workers", r.cfg.Workers),
+		logger.Int("batch_size", r.cfg.BatchSize))
+
+	wakes := make([]chan struct{}, r.cfg.Workers)
+	for i := range wakes {
+		wakes[i] = make(chan struct{}, 1)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.listen(ctx, wakes)
+	}()
+
+	for i, wake := range wakes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx, i, wake)
+		}()
+	}
+	wg.Wait()
+
+	r.logger.Info("outbox relayer stopped due to context cancellation")
+}
+
+// work runs the loop of a single relayer worker until the context is canceled.
+func (r *Relayer) work(ctx context.Context, worker int, wake <-chan struct{}) {
+	timer := time.NewTimer(r.cfg.Interval)
+	defer timer.Stop()
+
+	for {
+		r.drain(ctx, worker)
+
+		timer.Reset(r.cfg.Interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// drain processes batches until the outbox has no more due events, an error
+// occurs, or the context is canceled.
+func (r *Relayer) drain(ctx context.Context, worker int) {
+	for ctx.Err() == nil {
+		n, err := r.processBatch(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("failed to process outbox batch",
+					logger.Int("worker", worker),
+					logger.Error(err))
+			}
+			return
+		}
+		if n < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// listen holds a LISTEN connection on the outbox channel and signals every
+// worker on each notification. The connection is re-established after a
+// failure, and a wake-up is signaled on reconnect to pick up events notified
+// meanwhile.
+func (r *Relayer) listen(ctx context.Context, wakes []chan struct{}) {
+	for ctx.Err() == nil {
+		listener, err := r.db.Listen(ctx, r.opts.channel())
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("failed to listen on outbox channel", logger.Error(err))
+			}
+		} else {
+			notify(wakes)
+			r.waitForNotifications(ctx, listener, wakes)
+
+			closeCtx, cancel := context.WithTimeout(context.Background(), r.cfg.Interval)
+			if err := listener.Close(closeCtx); err != nil {
+				r.logger.Warn("failed to close outbox listener", logger.Error(err))
+			}
+			cancel()
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.cfg.Interval):
+		}
+	}
+}
+
+// waitForNotifications forwards notifications from the listener to the
+// workers until the listener fails or the context is canceled.
+func (r *Relayer) waitForNotifications(ctx context.Context,
+	listener db.Listener, wakes []chan struct{}) {
+	for {
+		if _, err := listener.WaitForNotification(ctx); err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("outbox listener failed", logger.Error(err))
+			}
+			return
+		}
+		notify(wakes)
+	}
+}
+
+// notify signals every wake channel without blocking if a wake-up is already
+// pending.
+func notify(wakes []chan struct{}) {
+	for _, wake := range wakes {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// processBatch retrieves a batch of pending outbox events that are due within
+// a transaction, publishes them concurrently and records the result of each
+// publish. Events of the same aggregate are published in insertion order.
+// Events that fail to be published are scheduled for a later retry.
+// It returns the number of events in the batch.
+func (r *Relayer) processBatch(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+
+	// only the oldest pending event of each aggregate is selected, so a later
+	// event is never published while an earlier one is still pending, backing
+	// off or locked by another worker. This also guarantees that events in
+	// the same batch, published concurrently, belong to different aggregates.
+	var outboxes []domain.Outbox
+	query := fmt.Sprintf(`
+		SELECT o.id, o.aggregate_id, o.aggregate_type, o.event_type, o.payload, o.content_type, o.status,
+			o.created_at, o.updated_at, o.sent_at, o.attempts, o.last_error, o.next_attempt_at
+		FROM %[1]s o
+		WHERE o.status = 'PENDING' AND o.next_attempt_at <= now()
+			AND NOT EXISTS (
+				SELECT 1
+				FROM %[1]s p
+				WHERE p.aggregate_id = o.aggregate_id
+					AND p.status = 'PENDING'
+					AND p.id < o.id
+			)
+		ORDER BY o.id
+		LIMIT $1
+		FOR UPDATE OF o SKIP LOCKED
+	`, r.opts.table("outbox"))
+
+	if err := tx.Select(ctx, &outboxes, query, r.cfg.BatchSize); err != nil {
+		_ = tx.Rollback(ctx)
+		return 0, fmt.Errorf("select outbox: %w", err)
+	}
+
+	// the transaction is not safe for concurrent use, so events are published
+	// concurrently and their rows updated afterwards.
+	errs := r.publishAll(outboxes)
+	for i := range outboxes {
+		o := &outboxes[i]
+		if errs[i] != nil {
+			r.logger.Error("failed to publish outbox event",
+				logger.Int("id", int(o.ID)),
+				logger.String("event_type", o.EventType),
+				logger.Error(errs[i]))
+			if err := r.markFailed(ctx, tx, o, errs[i]); err != nil {
+				r.logger.Error("failed to record outbox event failure",
+					logger.Int("id", int(o.ID)),
+					logger.Error(err))
+			}
+			continue
+		}
+		if err := r.markSent(ctx, tx, o); err != nil {
+			r.logger.Error("failed to mark outbox event as sent",
+				logger.Int("id", int(o.ID)),
+				logger.Error(err))
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return len(outboxes), nil
+}
+
+// publishAll publishes the given outbox events with at most MaxInFlight
+// concurrent publishes, and returns the publish error of each event.
+func (r *Relayer) publishAll(outboxes []domain.Outbox) []error {
+	errs := make([]error, len(outboxes))
+	sem := make(chan struct{}, r.cfg.MaxInFlight)
+
+	var wg sync.WaitGroup
+	for i := range outboxes {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = r.publish(&outboxes[i])
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// publish sends a single outbox event to Kafka as a CloudEvent, keyed by its
+// aggregate ID. The outbox row ID is used as the event ID, so consumers can
+// deduplicate events published more than once.
+func (r *Relayer) publish(o *domain.Outbox) error {
+	topic := r.cfg.Topic(o.EventType)
+	event := &kafka.Event{
+		ID:          strconv.FormatInt(o.ID, 10),
+		Type:        o.EventType,
+		Source:      r.cfg.Source,
+		Subject:     o.AggregateID.String(),
+		Time:        o.CreatedAt,
+		ContentType: o.ContentType,
+		Data:        o.Payload,
+	}
+	if event.ContentType == "" {
+		event.ContentType = kafka.ContentTypeJSON
+	}
+	if err := r.publisher.SendEvent(topic,
+		[]byte(o.AggregateID.String()), event); err != nil {
+		return fmt.Errorf("publish event to topic %s: %w", topic, err)
+	}
+
+	r.logger.Debug("outbox event published",
+		logger.Int("id", int(o.ID)),
+		logger.String("topic", topic))
+	return nil
+}
+
+// markSent marks the given outbox event as sent within the transaction.
+func (r *Relayer) markSent(ctx context.Context, tx db.Tx, o *domain.Outbox) error {
+	now := time.Now()
+	update := fmt.Sprintf(`
+		UPDATE %s
+		SET status = $1, sent_at = $2, updated_at = $2
+		WHERE id = $3
+	`, r.opts.table("outbox"))
+	if _, err := tx.Exec(ctx, update, domain.OutboxStatusSent, now, o.ID); err != nil {
+		return fmt.Errorf("mark outbox as sent: %w", err)
+	}
+	return nil
+}
+
+// markFailed records a failed publish attempt for the given outbox event. The
+// event is rescheduled with backoff, or moved to DEAD once the maximum number
+// of attempts is reached.
+func (r *Relayer) markFailed(ctx context.Context,
+	tx db.Tx, o *domain.Outbox, cause error) error {
+	now := time.Now()
+	attempts := o.Attempts + 1
+	lastError := cause.Error()
+	if len(lastError) > maxLastErrorLength {
+		lastError = strings.ToValidUTF8(lastError[:maxLastErrorLength], "")
+	}
+
+	status := domain.OutboxStatusPending
+	nextAttemptAt := now.Add(retry.Backoff(attempts, r.cfg.Retry.BaseBackoff, r.cfg.Retry.MaxBackoff))
+	if attempts >= r.cfg.Retry.MaxAttempts {
+		status = domain.OutboxStatusDead
+		r.logger.Warn("outbox event moved to dead status",
+			logger.Int("id", int(o.ID)),
+			logger.String("event_type", o.EventType),
+			logger.Int("attempts", attempts))
+	}
+
+	update := fmt.Sprintf(`
+		UPDATE %s
+		SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4, updated_at = $5
+		WHERE id = $6
+	`, r.opts.table("outbox"))
+	if _, err := tx.Exec(ctx, update,
+		status, attempts, lastError, nextAttemptAt, now, o.ID); err != nil {
+		return fmt.Errorf("mark outbox as failed: %w", err)
+	}
+	return nil
+}
+
+// DefaultTopic derives the Kafka topic from the event type itself
+// (e.g. "payment_failed" -> "payment.failed").
+func DefaultTopic(eventType string) string {
+	return strings.ReplaceAll(eventType, "_", ".")
+}
@@ -0,0 +1,334 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/db"
+	"payment-system/pkg/domain"
+	"payment-system/pkg/kafka"
+	"payment-system/pkg/logger"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseBackoff: time.Second,
+	MaxBackoff:  10 * time.Second,
+}
+
+var testConfig = RelayerConfig{
+	BatchSize:   10,
+	Interval:    time.Second,
+	MaxInFlight: 4,
+	Workers:     1,
+	Retry:       testRetryPolicy,
+	Topic: func(eventType string) string {
+		return "topic." + eventType
+	},
+	Source: "/test",
+}
+
+type sentMessage struct {
+	topic string
+	key   []byte
+	event *kafka.Event
+}
+
+type MockPublisher struct {
+	Messages    []sentMessage
+	Err         error
+	Delay       time.Duration
+	MaxInFlight int
+
+	mu       sync.Mutex
+	inFlight int
+}
+
+func (m *MockPublisher) SendEvent(topic string, key []byte, event *kafka.Event) error {
+	m.mu.Lock()
+	m.inFlight++
+	m.MaxInFlight = max(m.MaxInFlight, m.inFlight)
+	m.mu.Unlock()
+
+	time.Sleep(m.Delay)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Messages = append(m.Messages, sentMessage{topic: topic, key: key, event: event})
+	return nil
+}
+
+type MockTx struct {
+	Outboxes    []domain.Outbox
+	Queries     []string
+	ExecQueries []string
+	Execs       [][]any
+	Committed   bool
+}
+
+func (m *MockTx) Select(ctx context.Context, dest any, query string, args ...any) error {
+	*dest.(*[]domain.Outbox) = m.Outboxes
+	return nil
+}
+
+func (m *MockTx) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	m.ExecQueries = append(m.ExecQueries, query)
+	m.Execs = append(m.Execs, args)
+	return 1, nil
+}
+
+func (m *MockTx) QueryRow(ctx context.Context, dest any, query string, args ...any) error {
+	m.Queries = append(m.Queries, query)
+	m.Execs = append(m.Execs, args)
+	*dest.(*int64) = int64(len(m.Execs))
+	return nil
+}
+
+func (m *MockTx) Commit(ctx context.Context) error {
+	m.Committed = true
+	return nil
+}
+
+func (m *MockTx) Rollback(ctx context.Context) error { return nil }
+
+type MockListener struct {
+	Notifications chan *db.Notification
+	Listening     chan struct{}
+	CloseDelay    time.Duration
+	closed        atomic.Bool
+}
+
+func (m *MockListener) WaitForNotification(ctx context.Context) (*db.Notification, error) {
+	if m.Listening != nil {
+		select {
+		case m.Listening <- struct{}{}:
+		default:
+		}
+	}
+	select {
+	case n := <-m.Notifications:
+		return n, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *MockListener) Close(ctx context.Context) error {
+	time.Sleep(m.CloseDelay)
+	m.closed.Store(true)
+	return nil
+}
+
+type MockDB struct {
+	db.DB
+	Tx       *MockTx
+	Listener *MockListener
+}
+
+func (m *MockDB) BeginTx(ctx context.Context) (db.Tx, error) {
+	return m.Tx, nil
+}
+
+func (m *MockDB) Listen(ctx context.Context, channel string) (db.Listener, error) {
+	return m.Listener, nil
+}
+
+// TestProcessBatch_Success verifies that every selected outbox event is
+// published to the topic derived from its event type, keyed by aggregate ID,
+// with its content type (JSON by default), and marked as sent.
+func TestProcessBatch_Success(t *testing.T) {
+	outboxes := []domain.Outbox{
+		{ID: 7, AggregateID: uuid.New(), EventType: "payment_created", Payload: []byte(`{"id":7}`)},
+		{ID: 8, AggregateID: uuid.New(), EventType: "payment_created", Payload: []byte{0x0a, 0x01},
+			ContentType: kafka.ContentTypeProtobuf},
+	}
+	tx := &MockTx{Outboxes: outboxes}
+	publisher := &MockPublisher{}
+	c := NewRelayer(&MockDB{Tx: tx}, publisher, logger.NewNoopLogger(), testConfig)
+
+	n, err := c.processBatch(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.True(t, tx.Committed)
+
+	require.Len(t, publisher.Messages, 2)
+	for _, msg := range publisher.Messages {
+		require.Equal(t, "topic.payment_created", msg.topic)
+	}
+
+	events := make(map[string]sentMessage)
+	for _, msg := range publisher.Messages {
+		events[msg.event.ID] = msg
+	}
+	for _, o := range outboxes {
+		msg, ok := events[strconv.FormatInt(o.ID, 10)]
+		require.True(t, ok)
+		require.Equal(t, o.AggregateID.String(), string(msg.key))
+		require.Equal(t, "payment_created", msg.event.Type)
+		require.Equal(t, "/test", msg.event.Source)
+		require.Equal(t, o.Payload, msg.event.Data)
+		if o.ContentType == "" {
+			require.Equal(t, kafka.ContentTypeJSON, msg.event.ContentType)
+		} else {
+			require.Equal(t, o.ContentType, msg.event.ContentType)
+		}
+	}
+
+	require.Len(t, tx.Execs, 2)
+	for i, args := range tx.Execs {
+		require.Equal(t, domain.OutboxStatusSent, args[0])
+		require.Equal(t, outboxes[i].ID, args[2])
+	}
+}
+
+// TestProcessBatch_PublishError checks that an event is rescheduled instead of
+// being marked as sent when publishing to Kafka fails.
+func TestProcessBatch_PublishError(t *testing.T) {
+	o := domain.Outbox{ID: 1, AggregateID: uuid.New(), EventType: "payment_created"}
+	tx := &MockTx{Outboxes: []domain.Outbox{o}}
+	publisher := &MockPublisher{Err: errors.New("broker unavailable")}
+	c := NewRelayer(&MockDB{Tx: tx}, publisher, logger.NewNoopLogger(), testConfig)
+
+	_, err := c.processBatch(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, tx.Execs, 1)
+	require.Equal(t, domain.OutboxStatusPending, tx.Execs[0][0])
+	require.Equal(t, 1, tx.Execs[0][1])
+}
+
+// TestPublishAll_MaxInFlight checks that no more than MaxInFlight events are
+// published at the same time.
+func TestPublishAll_MaxInFlight(t *testing.T) {
+	cfg := testConfig
+	cfg.MaxInFlight = 2
+	publisher := &MockPublisher{Delay: 10 * time.Millisecond}
+	c := NewRelayer(nil, publisher, logger.NewNoopLogger(), cfg)
+
+	outboxes := make([]domain.Outbox, 6)
+	for i := range outboxes {
+		outboxes[i] = domain.Outbox{ID: int64(i), AggregateID: uuid.New(), EventType: "payment_created"}
+	}
+
+	errs := c.publishAll(outboxes)
+	require.Len(t, errs, len(outboxes))
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Len(t, publisher.Messages, len(outboxes))
+	require.Equal(t, 2, publisher.MaxInFlight)
+}
+
+// TestDefaultTopic checks the default mapping between event types and Kafka
+// topics.
+func TestDefaultTopic(t *testing.T) {
+	require.Equal(t, "payment.failed", DefaultTopic("payment_failed"))
+}
+
+// TestMarkFailed_Retry checks that a failed event below the attempt limit is
+// kept pending and rescheduled in the future.
+func TestMarkFailed_Retry(t *testing.T) {
+	tx := &MockTx{}
+	c := NewRelayer(nil, &MockPublisher{}, logger.NewNoopLogger(), testConfig)
+
+	o := &domain.Outbox{ID: 3, Attempts: 0}
+	before := time.Now()
+
+	err := c.markFailed(context.Background(), tx, o, errors.New("broker unavailable"))
+	require.NoError(t, err)
+
+	require.Len(t, tx.Execs, 1)
+	args := tx.Execs[0]
+	require.Equal(t, domain.OutboxStatusPending, args[0])
+	require.Equal(t, 1, args[1])
+	require.Equal(t, "broker unavailable", args[2])
+	require.True(t, args[3].(time.Time).After(before))
+}
+
+// TestMarkFailed_Dead checks that an event reaching the maximum number of
+// attempts is moved to the DEAD status.
+func TestMarkFailed_Dead(t *testing.T) {
+	tx := &MockTx{}
+	c := NewRelayer(nil, &MockPublisher{}, logger.NewNoopLogger(), testConfig)
+
+	o := &domain.Outbox{ID: 3, Attempts: 2}
+
+	err := c.markFailed(context.Background(), tx, o, errors.New("broker unavailable"))
+	require.NoError(t, err)
+
+	require.Len(t, tx.Execs, 1)
+	require.Equal(t, domain.OutboxStatusDead, tx.Execs[0][0])
+	require.Equal(t, 3, tx.Execs[0][1])
+}
+
+// TestListen_WakesOnNotification checks that the consumer is woken up when it
+// starts listening and on every notification received on the outbox channel.
+func TestListen_WakesOnNotification(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener := &MockListener{Notifications: make(chan *db.Notification)}
+	mockDB := &MockDB{Listener: listener}
+	c := NewRelayer(mockDB, &MockPublisher{}, logger.NewNoopLogger(), testConfig)
+
+	wake := make(chan struct{}, 1)
+	go c.listen(ctx, []chan struct{}{wake})
+
+	select {
+	case <-wake:
+	case <-time.After(time.Second):
+		t.Fatal("expected wake-up after listen")
+	}
+
+	listener.Notifications <- &db.Notification{Channel: Channel()}
+	select {
+	case <-wake:
+	case <-time.After(time.Second):
+		t.Fatal("expected wake-up after notification")
+	}
+}
+
+// TestStart_WaitsForListener verifies that Start does not return while the
+// outbox listener still holds its connection.
+func TestStart_WaitsForListener(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	listener := &MockListener{
+		Notifications: make(chan *db.Notification),
+		Listening:     make(chan struct{}, 1),
+		CloseDelay:    50 * time.Millisecond,
+	}
+	mockDB := &MockDB{Tx: &MockTx{}, Listener: listener}
+	c := NewRelayer(mockDB, &MockPublisher{}, logger.NewNoopLogger(), testConfig)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Start(ctx)
+	}()
+
+	select {
+	case <-listener.Listening:
+	case <-time.After(time.Second):
+		t.Fatal("expected listener to be waiting for notifications")
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Start to return after cancellation")
+	}
+	require.True(t, listener.closed.Load())
+}
@@ -0,0 +1,26 @@
+// Package retry computes the delays between the attempts of operations that
+// are retried after a failure.
+package retry
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff returns the delay before retrying after the given failed attempt,
+// counted from 1. The delay doubles from base up to maxDelay, and half of it
+// is randomized so callers that failed together don't retry together.
+func Backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
@@ -0,0 +1,25 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/test-go/testify/require"
+)
+
+// TestBackoff checks that the backoff grows exponentially with jitter and
+// never exceeds the maximum.
+func TestBackoff(t *testing.T) {
+	base, maxDelay := time.Second, 10*time.Second
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay := Backoff(attempt, base, maxDelay)
+		require.True(t, delay <= maxDelay)
+		require.True(t, delay >= base/2)
+	}
+
+	delay := Backoff(3, base, maxDelay)
+	require.True(t, delay >= 2*time.Second)
+	require.True(t, delay <= 4*time.Second)
+
+	require.Equal(t, time.Duration(0), Backoff(1, 0, maxDelay))
+}
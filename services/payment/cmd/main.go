@@ -6,13 +6,19 @@ import (
 	"os/signal"
 	"payment-system/pkg/config"
 	"payment-system/pkg/db"
+	"payment-system/pkg/events"
+	"payment-system/pkg/kafka"
 	"payment-system/pkg/logger"
+	"payment-system/pkg/outbox"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	paymentCfg "github.com/walker-16/payment-system/services/payment/internal/config"
+	"github.com/walker-16/payment-system/services/payment/internal/consumer"
+	"github.com/walker-16/payment-system/services/payment/internal/expiry"
 	"github.com/walker-16/payment-system/services/payment/internal/handler"
 	"github.com/walker-16/payment-system/services/payment/internal/order"
 	"github.com/walker-16/payment-system/services/payment/internal/repository"
@@ -39,6 +45,10 @@ func main() {
 	if err != nil {
 		logger.Fatal("failed to load configuration", "error", err)
 	}
+	contentType, err := events.ContentTypeFor(cfg.Kafka.EventEncoding)
+	if err != nil {
+		logger.Fatal("invalid event encoding", "error", err)
+	}
 
 	// initialize db client.
 	dbConfig := db.Config{
@@ -55,12 +65,95 @@ func main() {
 	}
 	defer db.Close()
 
-	// TODO:
-	// initalize consumer for process pendint outbox and send event to kafka.
-	// modify gracefull shutdown.
+	// create or update the outbox tables of the payment schema.
+	if err := outbox.Migrate(ctx, db, outbox.WithSchema(paymentCfg.Schema)); err != nil {
+		logger.Fatal("failed to migrate outbox", "error", err)
+	}
+
+	// initialize kafka producer.
+	producer, err := kafka.NewProducer(cfg.Kafka.Brokers, paymentCfg.AppName, logger)
+	if err != nil {
+		logger.Fatal("failed to create kafka producer", "error", err)
+	}
+	defer producer.Close()
+
+	// background workers are stopped after the server on shutdown.
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+
+	// initialize relayer for process pending outbox and send event to kafka.
+	relayerConfig := outbox.RelayerConfig{
+		BatchSize:   cfg.Outbox.BatchSize,
+		Interval:    cfg.Outbox.PollInterval,
+		MaxInFlight: cfg.Outbox.MaxInFlight,
+		Workers:     cfg.Outbox.Workers,
+		Retry: outbox.RetryPolicy{
+			MaxAttempts: cfg.Outbox.MaxAttempts,
+			BaseBackoff: cfg.Outbox.BaseBackoff,
+			MaxBackoff:  cfg.Outbox.MaxBackoff,
+		},
+		Topic:  events.Topic,
+		Source: paymentCfg.EventSource,
+	}
+	outboxRelayer := outbox.NewRelayer(db, producer, logger, relayerConfig,
+		outbox.WithSchema(paymentCfg.Schema))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		outboxRelayer.Start(workersCtx)
+	}()
+
+	// initialize janitor for archive published and purge dead outbox events.
+	outboxJanitor := outbox.NewJanitor(db, logger, outbox.JanitorConfig{
+		Interval:      cfg.Outbox.Janitor.Interval,
+		Retention:     cfg.Outbox.Janitor.Retention,
+		DeadRetention: cfg.Outbox.Janitor.DeadRetention,
+		BatchSize:     cfg.Outbox.Janitor.BatchSize,
+		DryRun:        cfg.Outbox.Janitor.DryRun,
+	}, outbox.WithSchema(paymentCfg.Schema))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		outboxJanitor.Start(workersCtx)
+	}()
+
+	// initialize consumer for apply wallet and processor events to payments.
+	paymentRepository := repository.NewPaymentRepository(db, contentType)
+	eventHandler := consumer.NewPaymentEventHandler(paymentRepository, logger)
+	eventConsumer, err := kafka.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.ConsumerGroup,
+		consumer.Topics, events.NewConsumerHandler(
+			func(meta *kafka.Event, e events.Event) error {
+				return eventHandler.Handle(workersCtx, meta, e)
+			}), logger)
+	if err != nil {
+		logger.Fatal("failed to create kafka consumer", "error", err)
+	}
+	defer eventConsumer.Close()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := eventConsumer.Start(workersCtx); err != nil && workersCtx.Err() == nil {
+			logger.Error("payment event consumer stopped", "error", err)
+		}
+	}()
+
+	// initialize sweeper for expire payments stuck in a status.
+	expirySweeper := expiry.NewSweeper(paymentRepository, logger, expiry.Config{
+		Interval:         cfg.Expiry.Interval,
+		BatchSize:        cfg.Expiry.BatchSize,
+		PendingTTL:       cfg.Expiry.PendingTTL,
+		FundsReservedTTL: cfg.Expiry.FundsReservedTTL,
+		ProcessingTTL:    cfg.Expiry.ProcessingTTL,
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		expirySweeper.Start(workersCtx)
+	}()
 
 	// create and run server.
-	app := newServer(db, logger)
+	app := newServer(paymentRepository, cfg, logger)
 	serverErr := make(chan error, 1)
 	go func() {
 		logger.Info("payment server started", "port", cfg.Port)
@@ -84,10 +177,25 @@ func main() {
 		logger.Error("failed to shutdown payment server gracefully", "error", err)
 	}
 
+	// stop the outbox relayer, janitor, event consumer and expiry sweeper and
+	// wait for the in-flight batches to finish.
+	stopWorkers()
+	outboxDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(outboxDone)
+	}()
+	select {
+	case <-outboxDone:
+	case <-shutdownCtx.Done():
+		logger.Error("timed out waiting for background workers to stop")
+	}
+
 	logger.Info("payment server exited succesfully")
 }
 
-func newServer(db db.DB, logger logger.Logger) *fiber.App {
+func newServer(repository repository.PaymentRepo,
+	cfg *paymentCfg.PaymentConfiguration, logger logger.Logger) *fiber.App {
 	// create a new Fiber app.
 	app := fiber.New()
 
@@ -95,23 +203,30 @@ func newServer(db db.DB, logger logger.Logger) *fiber.App {
 	app.Use(recover.New())
 
 	// Register routes.
-	registerRoutes(app, db, logger)
+	registerRoutes(app, repository, cfg, logger)
 	return app
 }
 
-func registerRoutes(app *fiber.App, db db.DB, logger logger.Logger) {
+func registerRoutes(app *fiber.App, repository repository.PaymentRepo,
+	cfg *paymentCfg.PaymentConfiguration, logger logger.Logger) {
 	v1 := app.Group("/v1")
-	orderService := newOrderService()
-	repository := repository.NewPaymentRepository(db)
-	h := handler.NewPaymentHandler(orderService, repository, logger)
+	orderService := newOrderService(cfg.Order)
+	h := handler.NewPaymentHandler(orderService, repository, logger,
+		handler.WithPageSize(cfg.List.DefaultPageSize, cfg.List.MaxPageSize))
 	v1.Post("/payments", h.CreatePayment)
+	v1.Get("/payments", h.ListPayments)
+	v1.Get("/payments/:payment_id", h.GetPayment)
+	v1.Post("/payments/:payment_id/refunds", h.CreateRefund)
+	v1.Post("/payments/:payment_id/cancel", h.CancelPayment)
 }
 
-// NOTE: A mock implementation of the Order Service is used here, as the actual
-// service is out of scope for this exercise. However, the interface and structure
-// are defined. In a production system, this would be replaced with a proper
-// service client that handles retries and robust error handling when communicating
-// with the Order Service.
-func newOrderService() order.Service {
-	return order.NewMockOrderService(order.MockSuccess)
+// newOrderService returns the HTTP client of the Order Service.
+func newOrderService(cfg paymentCfg.OrderConfig) order.Service {
+	return order.NewHTTPService(order.HTTPConfig{
+		BaseURL:     cfg.URL,
+		Timeout:     cfg.Timeout,
+		MaxRetries:  cfg.MaxRetries,
+		BaseBackoff: cfg.BaseBackoff,
+		MaxBackoff:  cfg.MaxBackoff,
+	})
 }
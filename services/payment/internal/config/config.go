@@ -4,11 +4,23 @@ import "time"
 
 const AppName = "Payment"
 
+// Schema is the database schema owned by the payment service.
+const Schema = "payment"
+
+// EventSource is the CloudEvents source of the events published by the
+// payment service.
+const EventSource = "/payment-system/payment"
+
 // PaymentConfiguration holds the configuration for the payment service.
 type PaymentConfiguration struct {
 	LogLevel string `env:"LOG_LEVEL,default=INFO"`
 	Port     string `env:"PORT,default=8000"`
 	DB       DBConfig
+	Kafka    KafkaConfig
+	Outbox   OutboxConfig
+	List     ListConfig
+	Expiry   ExpiryConfig
+	Order    OrderConfig
 }
 
 // DBConfig holds database connection and pool settings.
@@ -19,3 +31,58 @@ type DBConfig struct {
 	MaxConnIdleTime time.Duration `env:"DB_MAX_CONN_IDLE_TIME,default=30m"`
 	MaxConnLifetime time.Duration `env:"DB_MAX_CONN_LIFETIME,default=1h"`
 }
+
+// ListConfig holds the page sizes of the payment listing endpoint.
+type ListConfig struct {
+	DefaultPageSize int `env:"LIST_DEFAULT_PAGE_SIZE,default=20"`
+	MaxPageSize     int `env:"LIST_MAX_PAGE_SIZE,default=100"`
+}
+
+// ExpiryConfig holds the payment expiry sweeper settings. A zero TTL disables
+// the expiry of the matching status.
+type ExpiryConfig struct {
+	Interval         time.Duration `env:"EXPIRY_INTERVAL,default=1m"`
+	BatchSize        int           `env:"EXPIRY_BATCH_SIZE,default=100"`
+	PendingTTL       time.Duration `env:"EXPIRY_PENDING_TTL,default=15m"`
+	FundsReservedTTL time.Duration `env:"EXPIRY_FUNDS_RESERVED_TTL,default=30m"`
+	ProcessingTTL    time.Duration `env:"EXPIRY_PROCESSING_TTL,default=1h"`
+}
+
+// OrderConfig holds the Order Service client settings.
+type OrderConfig struct {
+	URL         string        `env:"ORDER_SERVICE_URL,required"`
+	Timeout     time.Duration `env:"ORDER_SERVICE_TIMEOUT,default=2s"`
+	MaxRetries  int           `env:"ORDER_SERVICE_MAX_RETRIES,default=2"`
+	BaseBackoff time.Duration `env:"ORDER_SERVICE_BASE_BACKOFF,default=100ms"`
+	MaxBackoff  time.Duration `env:"ORDER_SERVICE_MAX_BACKOFF,default=1s"`
+}
+
+// KafkaConfig holds Kafka connection and event encoding settings.
+type KafkaConfig struct {
+	Brokers []string `env:"KAFKA_BROKERS,required"`
+	// ConsumerGroup is the consumer group of the payment event consumer.
+	ConsumerGroup string `env:"KAFKA_CONSUMER_GROUP,default=payment"`
+	// EventEncoding selects the encoding of published events, json or protobuf.
+	EventEncoding string `env:"KAFKA_EVENT_ENCODING,default=json"`
+}
+
+// OutboxConfig holds the outbox relayer settings.
+type OutboxConfig struct {
+	BatchSize    int           `env:"OUTBOX_BATCH_SIZE,default=10"`
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL,default=1s"`
+	MaxInFlight  int           `env:"OUTBOX_MAX_IN_FLIGHT,default=10"`
+	Workers      int           `env:"OUTBOX_WORKERS,default=1"`
+	MaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS,default=10"`
+	BaseBackoff  time.Duration `env:"OUTBOX_BASE_BACKOFF,default=1s"`
+	MaxBackoff   time.Duration `env:"OUTBOX_MAX_BACKOFF,default=5m"`
+	Janitor      OutboxJanitorConfig
+}
+
+// OutboxJanitorConfig holds the outbox retention settings.
+type OutboxJanitorConfig struct {
+	Interval      time.Duration `env:"OUTBOX_JANITOR_INTERVAL,default=1h"`
+	Retention     time.Duration `env:"OUTBOX_RETENTION,default=168h"`
+	DeadRetention time.Duration `env:"OUTBOX_DEAD_RETENTION,default=720h"`
+	BatchSize     int           `env:"OUTBOX_JANITOR_BATCH_SIZE,default=500"`
+	DryRun        bool          `env:"OUTBOX_JANITOR_DRY_RUN,default=false"`
+}
@@ -1,93 +1,117 @@
+// Package consumer applies the events published by the wallet and processor
+// services to payments.
 package consumer
 
 import (
 	"context"
-	"fmt"
-	"payment-system/pkg/db"
-	"payment-system/pkg/domain"
+	"errors"
+	"payment-system/pkg/events"
 	"payment-system/pkg/kafka"
 	"payment-system/pkg/logger"
-	"time"
-)
 
-// TODO: add env var to modify default batch size.
-const batchSize = 10
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
 
-// OutboxConsumer is responsible for polling the outbox table, processing events,
-// and publishing them to Kafka.
-type OutboxConsumer struct {
-	db       db.DB
-	producer *kafka.Producer
-	logger   logger.Logger
-	interval time.Duration
+// Topics are the Kafka topics consumed by the payment service.
+var Topics = []string{
+	events.TopicFundsReserved,
+	events.TopicFundsInsufficient,
+	events.TopicFundsReleased,
+	events.TopicPaymentCompleted,
+	events.TopicPaymentFailed,
 }
 
-// NewOutboxConsumer creates a new OutboxConsumer with the given database, Kafka producer,
-// logger, and processing interval.
-func NewOutboxConsumer(db db.DB, producer *kafka.Producer,
-	logger logger.Logger, interval time.Duration) *OutboxConsumer {
-	return &OutboxConsumer{
-		db:       db,
-		producer: producer,
-		logger:   logger,
-		interval: interval,
-	}
+// EventRepo applies consumed events to payments.
+type EventRepo interface {
+	ApplyEventStatus(ctx context.Context, event repository.ConsumedEvent,
+		paymentID uuid.UUID, change domain.StatusChange) (*domain.Payment, error)
 }
 
-// Start launches the consumer loop which continuously polls and processes outbox events
-// until the provided context is canceled.
-func (c *OutboxConsumer) Start(ctx context.Context) {
-	c.logger.Info("starting outbox consumer")
-
-	for {
-		select {
-		case <-ctx.Done():
-			c.logger.Info("outbox consumer stopped due to context cancellation")
-			return
-		default:
-			if err := c.processBatch(ctx); err != nil {
-				c.logger.Error("failed to process outbox batch", logger.Error(err))
-			}
-			time.Sleep(c.interval)
-		}
-	}
+// PaymentEventHandler moves payments through their lifecycle as the wallet
+// and the processor report the outcome of each step.
+type PaymentEventHandler struct {
+	repository EventRepo
+	logger     logger.Logger
 }
 
-// processBatch retrieves a batch of pending outbox events within a transaction
-// and processes each event.
-func (c *OutboxConsumer) processBatch(ctx context.Context) error {
-	tx, err := c.db.BeginTx(ctx)
-	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
+// NewPaymentEventHandler creates a new instance of PaymentEventHandler.
+func NewPaymentEventHandler(repository EventRepo, logger logger.Logger) *PaymentEventHandler {
+	return &PaymentEventHandler{
+		repository: repository,
+		logger:     logger,
 	}
+}
 
-	var outboxes []domain.Outbox
-	query := `
-		SELECT id, aggregate_id, aggregate_type, event_type, payload, status, created_at, updated_at
-		FROM payment.outbox
-		WHERE status = 'PENDING'
-		ORDER BY created_at
-		LIMIT $1
-		FOR UPDATE SKIP LOCKED
-	`
-
-	if err := tx.Select(ctx, &outboxes, query, batchSize); err != nil {
-		_ = tx.Rollback(ctx)
-		return fmt.Errorf("select outbox: %w", err)
+// Handle applies the given event. Redelivered events, events of unknown
+// payments and stale events whose transition is no longer allowed are
+// skipped, so only transient failures are returned. The kafka consumer
+// retries those without committing the offset of the event.
+func (h *PaymentEventHandler) Handle(ctx context.Context, meta *kafka.Event, e events.Event) error {
+	paymentID, change, ok := statusChange(e)
+	if !ok {
+		h.logger.Debug("ignoring event", logger.String("type", meta.Type))
+		return nil
 	}
+	change.SourceEvent = meta.Source + "/" + meta.ID
 
-	for _, o := range outboxes {
-		if err := c.processOutbox(ctx, tx, &o); err != nil {
-			c.logger.Error("failed to process outbox event", logger.Error(err))
-		}
+	consumed := repository.ConsumedEvent{Source: meta.Source, ID: meta.ID, Type: meta.Type}
+	payment, err := h.repository.ApplyEventStatus(ctx, consumed, paymentID, change)
+	switch {
+	case err == nil:
+		h.logger.Info("payment status updated",
+			logger.String("payment_id", paymentID.String()),
+			logger.String("status", string(payment.Status)),
+			logger.String("event", change.SourceEvent))
+		return nil
+	case errors.Is(err, repository.ErrEventAlreadyProcessed):
+		h.logger.Debug("skipping already processed event",
+			logger.String("event", change.SourceEvent))
+		return nil
+	case errors.Is(err, repository.ErrPaymentNotFound),
+		errors.Is(err, domain.ErrInvalidTransition):
+		h.logger.Warn("skipping event",
+			logger.String("payment_id", paymentID.String()),
+			logger.String("event", change.SourceEvent),
+			logger.Error(err))
+		return nil
+	default:
+		return err
 	}
-
-	return tx.Commit(ctx)
 }
 
-func (c *OutboxConsumer) processOutbox(ctx context.Context,
-	tx db.Tx, o *domain.Outbox) error {
-
-	//TODO: continue implementation.
-	return nil
+// statusChange returns the payment and status change caused by the event.
+func statusChange(e events.Event) (uuid.UUID, domain.StatusChange, bool) {
+	switch e := e.(type) {
+	case *events.FundsReservedV1:
+		return e.PaymentID, domain.StatusChange{
+			To:     domain.StatusFundsReserved,
+			Reason: "funds reserved",
+		}, true
+	case *events.FundsInsufficientV1:
+		return e.PaymentID, domain.StatusChange{
+			To:     domain.StatusFailed,
+			Reason: e.Reason,
+		}, true
+	case *events.FundsReleasedV1:
+		// the wallet released the hold on its own, the payment can no longer
+		// be captured.
+		return e.PaymentID, domain.StatusChange{
+			To:     domain.StatusFailed,
+			Reason: "funds released: " + e.Reason,
+		}, true
+	case *events.PaymentCompletedV1:
+		return e.PaymentID, domain.StatusChange{
+			To:     domain.StatusCompleted,
+			Reason: "payment completed",
+		}, true
+	case *events.PaymentFailedV1:
+		return e.PaymentID, domain.StatusChange{
+			To:     domain.StatusFailed,
+			Reason: e.Reason,
+		}, true
+	default:
+		return uuid.Nil, domain.StatusChange{}, false
+	}
 }
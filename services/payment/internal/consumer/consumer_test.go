@@ -0,0 +1,124 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/events"
+	"payment-system/pkg/kafka"
+	"payment-system/pkg/logger"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
+
+// MockEventRepo applies status changes to in-memory payments, recording the
+// processed events like the payment repository.
+type MockEventRepo struct {
+	Payments  map[uuid.UUID]*domain.Payment
+	Processed map[repository.ConsumedEvent]bool
+	Err       error
+}
+
+func (m *MockEventRepo) ApplyEventStatus(ctx context.Context, event repository.ConsumedEvent,
+	paymentID uuid.UUID, change domain.StatusChange) (*domain.Payment, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.Processed[event] {
+		return nil, repository.ErrEventAlreadyProcessed
+	}
+	payment, ok := m.Payments[paymentID]
+	if !ok {
+		return nil, repository.ErrPaymentNotFound
+	}
+	if err := payment.Status.ValidateTransition(change.To); err != nil {
+		return nil, err
+	}
+	m.Processed[event] = true
+	payment.Status = change.To
+	return payment, nil
+}
+
+func newMockEventRepo(payments ...*domain.Payment) *MockEventRepo {
+	repo := &MockEventRepo{
+		Payments:  map[uuid.UUID]*domain.Payment{},
+		Processed: map[repository.ConsumedEvent]bool{},
+	}
+	for _, p := range payments {
+		repo.Payments[p.PaymentID] = p
+	}
+	return repo
+}
+
+func newMeta(id string, e events.Event) *kafka.Event {
+	return &kafka.Event{ID: id, Type: e.EventType(), Source: "/test"}
+}
+
+// TestHandle_StatusChanges checks that each consumed event moves the payment
+// to the matching status.
+func TestHandle_StatusChanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		event func(paymentID uuid.UUID) events.Event
+		want  domain.PaymentStatus
+	}{
+		{"funds reserved", func(id uuid.UUID) events.Event {
+			return &events.FundsReservedV1{PaymentID: id}
+		}, domain.StatusFundsReserved},
+		{"funds insufficient", func(id uuid.UUID) events.Event {
+			return &events.FundsInsufficientV1{PaymentID: id, Reason: "balance too low"}
+		}, domain.StatusFailed},
+		{"funds released", func(id uuid.UUID) events.Event {
+			return &events.FundsReleasedV1{PaymentID: id, Reason: "hold expired"}
+		}, domain.StatusFailed},
+		{"payment completed", func(id uuid.UUID) events.Event {
+			return &events.PaymentCompletedV1{PaymentID: id, CompletedAt: time.Now()}
+		}, domain.StatusCompleted},
+		{"payment failed", func(id uuid.UUID) events.Event {
+			return &events.PaymentFailedV1{PaymentID: id, Reason: "declined"}
+		}, domain.StatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := &domain.Payment{PaymentID: uuid.New(), Status: domain.StatusPending}
+			h := NewPaymentEventHandler(newMockEventRepo(payment), logger.NewNoopLogger())
+
+			e := tt.event(payment.PaymentID)
+			require.NoError(t, h.Handle(context.Background(), newMeta("1", e), e))
+			require.Equal(t, tt.want, payment.Status)
+		})
+	}
+}
+
+// TestHandle_Skipped checks that redelivered, stale and unknown events are
+// acknowledged without changes while transient errors are returned.
+func TestHandle_Skipped(t *testing.T) {
+	payment := &domain.Payment{PaymentID: uuid.New(), Status: domain.StatusPending}
+	repo := newMockEventRepo(payment)
+	h := NewPaymentEventHandler(repo, logger.NewNoopLogger())
+	ctx := context.Background()
+
+	completed := &events.PaymentCompletedV1{PaymentID: payment.PaymentID}
+	require.NoError(t, h.Handle(ctx, newMeta("1", completed), completed))
+	require.Equal(t, domain.StatusCompleted, payment.Status)
+
+	// redelivered event.
+	require.NoError(t, h.Handle(ctx, newMeta("1", completed), completed))
+	// stale reservation consumed after the processor result.
+	reserved := &events.FundsReservedV1{PaymentID: payment.PaymentID}
+	require.NoError(t, h.Handle(ctx, newMeta("2", reserved), reserved))
+	require.Equal(t, domain.StatusCompleted, payment.Status)
+	// unknown payment.
+	failed := &events.PaymentFailedV1{PaymentID: uuid.New()}
+	require.NoError(t, h.Handle(ctx, newMeta("3", failed), failed))
+	// events not affecting payments.
+	requested := &events.PaymentRequestedV1{PaymentID: payment.PaymentID}
+	require.NoError(t, h.Handle(ctx, newMeta("4", requested), requested))
+
+	repo.Err = errors.New("connection refused")
+	require.Error(t, h.Handle(ctx, newMeta("5", failed), failed))
+}
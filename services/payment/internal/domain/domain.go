@@ -1,23 +1,26 @@
 package domain
 
 import (
+	"payment-system/pkg/money"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// AggregatePayment is the aggregate type of the events published for payments.
+const AggregatePayment = "payment"
+
 // Payment represents a payment record in the system.
 type Payment struct {
-	ID              int64     `db:"id"`
-	PaymentID       uuid.UUID `db:"payment_id"`
-	ExternalOrderID uuid.UUID `db:"external_order_id"`
-	UserID          uint32    `db:"user_id"`
-	IdempotencyKey  uuid.UUID `db:"idempotency_key"`
-	Amount          float64   `db:"amount"`
-	Currency        string    `db:"currency"`
-	Status          string    `db:"status"`
-	CreatedAt       time.Time `db:"created_at"`
-	UpdatedAt       time.Time `db:"updated_at"`
+	ID              int64         `db:"id"`
+	PaymentID       uuid.UUID     `db:"payment_id"`
+	ExternalOrderID uuid.UUID     `db:"external_order_id"`
+	UserID          uint32        `db:"user_id"`
+	IdempotencyKey  uuid.UUID     `db:"idempotency_key"`
+	RequestHash     string        `db:"request_hash"`
+	Amount          money.Money   `db:"-"` // amount and currency columns
+	Status          PaymentStatus `db:"status"`
+	FailureReason   *string       `db:"failure_reason"`
+	CreatedAt       time.Time     `db:"created_at"`
+	UpdatedAt       time.Time     `db:"updated_at"`
 }
-
-//TODO: modify to use http://godoc.org/github.com/shopspring/decimal in amount.
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefundStatusRequested is the status of a refund waiting for the wallet and
+// the processor to return the funds.
+const RefundStatusRequested = "REQUESTED"
+
+// Refund represents a full or partial refund of a completed payment.
+type Refund struct {
+	ID             int64       `db:"id"`
+	RefundID       uuid.UUID   `db:"refund_id"`
+	PaymentID      uuid.UUID   `db:"payment_id"`
+	UserID         uint32      `db:"user_id"`
+	IdempotencyKey uuid.UUID   `db:"idempotency_key"`
+	RequestHash    string      `db:"request_hash"`
+	Amount         money.Money `db:"-"` // amount and currency columns
+	Reason         *string     `db:"reason"`
+	Status         string      `db:"status"`
+	CreatedAt      time.Time   `db:"created_at"`
+	UpdatedAt      time.Time   `db:"updated_at"`
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrInvalidTransition is returned when a payment status change is not
+// allowed by the transition table.
+var ErrInvalidTransition = errors.New("invalid payment status transition")
+
+// PaymentStatus is the lifecycle status of a payment.
+type PaymentStatus string
+
+const (
+	// StatusPending is the status of a payment waiting for funds.
+	StatusPending PaymentStatus = "PENDING"
+	// StatusFundsReserved is the status of a payment whose funds are held in
+	// the wallet of the user.
+	StatusFundsReserved PaymentStatus = "FUNDS_RESERVED"
+	// StatusProcessing is the status of a payment sent to the processor.
+	StatusProcessing PaymentStatus = "PROCESSING"
+	// StatusCompleted is the status of a payment charged successfully.
+	StatusCompleted PaymentStatus = "COMPLETED"
+	// StatusFailed is the status of a payment that could not be charged.
+	StatusFailed PaymentStatus = "FAILED"
+	// StatusCancelled is the status of a payment cancelled before being charged.
+	StatusCancelled PaymentStatus = "CANCELLED"
+	// StatusExpired is the status of a payment that stayed too long without
+	// funds reserved or charged.
+	StatusExpired PaymentStatus = "EXPIRED"
+	// StatusPartiallyRefunded is the status of a completed payment with
+	// refunds below its amount.
+	StatusPartiallyRefunded PaymentStatus = "PARTIALLY_REFUNDED"
+	// StatusRefunded is the status of a completed payment fully refunded.
+	StatusRefunded PaymentStatus = "REFUNDED"
+)
+
+// transitions holds the statuses each status can move to. Statuses without
+// entry are final. A pending payment can complete directly since the
+// processor result may be consumed before the funds reservation.
+var transitions = map[PaymentStatus][]PaymentStatus{
+	StatusPending:       {StatusFundsReserved, StatusCompleted, StatusFailed, StatusCancelled, StatusExpired},
+	StatusFundsReserved: {StatusProcessing, StatusCompleted, StatusFailed, StatusCancelled, StatusExpired},
+	StatusProcessing:    {StatusCompleted, StatusFailed},
+	StatusCompleted:     {StatusPartiallyRefunded, StatusRefunded},
+	// further partial refunds keep the payment partially refunded.
+	StatusPartiallyRefunded: {StatusPartiallyRefunded, StatusRefunded},
+}
+
+// statuses holds every known status.
+var statuses = []PaymentStatus{
+	StatusPending, StatusFundsReserved, StatusProcessing, StatusCompleted,
+	StatusFailed, StatusCancelled, StatusExpired, StatusPartiallyRefunded, StatusRefunded,
+}
+
+// ParseStatus returns the status with the given name.
+func ParseStatus(s string) (PaymentStatus, error) {
+	status := PaymentStatus(s)
+	if !slices.Contains(statuses, status) {
+		return "", fmt.Errorf("unknown payment status %q", s)
+	}
+	return status, nil
+}
+
+// IsRefundable reports whether refunds can be requested for a payment with
+// the status.
+func (s PaymentStatus) IsRefundable() bool {
+	return s == StatusCompleted || s == StatusPartiallyRefunded
+}
+
+// IsFinal reports whether no transition is allowed from the status.
+func (s PaymentStatus) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// CanTransitionTo reports whether the status can move to the given status.
+func (s PaymentStatus) CanTransitionTo(to PaymentStatus) bool {
+	return slices.Contains(transitions[s], to)
+}
+
+// ValidateTransition returns ErrInvalidTransition if the status cannot move
+// to the given status.
+func (s PaymentStatus) ValidateTransition(to PaymentStatus) error {
+	if !s.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, s, to)
+	}
+	return nil
+}
+
+// StatusChange describes a requested payment status transition.
+type StatusChange struct {
+	To PaymentStatus
+	// Reason explains the change. It is stored as failure reason of failed
+	// and expired payments.
+	Reason string
+	// SourceEvent identifies the event or request that caused the change.
+	SourceEvent string
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/test-go/testify/require"
+)
+
+// TestPaymentStatus_Transitions checks the allowed and rejected transitions
+// of the payment lifecycle.
+func TestPaymentStatus_Transitions(t *testing.T) {
+	tests := []struct {
+		from, to PaymentStatus
+		allowed  bool
+	}{
+		{StatusPending, StatusFundsReserved, true},
+		{StatusPending, StatusCancelled, true},
+		{StatusPending, StatusCompleted, true},
+		{StatusPending, StatusRefunded, false},
+		{StatusFundsReserved, StatusProcessing, true},
+		{StatusProcessing, StatusCompleted, true},
+		{StatusProcessing, StatusCancelled, false},
+		{StatusCompleted, StatusRefunded, true},
+		{StatusCompleted, StatusPartiallyRefunded, true},
+		{StatusPartiallyRefunded, StatusPartiallyRefunded, true},
+		{StatusPartiallyRefunded, StatusRefunded, true},
+		{StatusRefunded, StatusPartiallyRefunded, false},
+		{StatusCompleted, StatusFailed, false},
+		{StatusFailed, StatusCompleted, false},
+		{StatusPending, StatusPending, false},
+		{StatusPending, StatusExpired, true},
+		{StatusFundsReserved, StatusExpired, true},
+		{StatusProcessing, StatusExpired, false},
+	}
+	for _, tt := range tests {
+		err := tt.from.ValidateTransition(tt.to)
+		if tt.allowed {
+			require.NoError(t, err, "%s to %s", tt.from, tt.to)
+		} else {
+			require.True(t, errors.Is(err, ErrInvalidTransition), "%s to %s", tt.from, tt.to)
+		}
+	}
+
+	for _, s := range []PaymentStatus{StatusFailed, StatusCancelled, StatusExpired, StatusRefunded} {
+		require.True(t, s.IsFinal(), s)
+	}
+	require.False(t, StatusPending.IsFinal())
+}
+
+// TestParseStatus checks that only known statuses are parsed.
+func TestParseStatus(t *testing.T) {
+	status, err := ParseStatus("COMPLETED")
+	require.NoError(t, err)
+	require.Equal(t, StatusCompleted, status)
+
+	_, err = ParseStatus("completed")
+	require.Error(t, err)
+}
@@ -0,0 +1,122 @@
+// Package expiry expires payments stuck in a status, for instance after an
+// event was lost.
+package expiry
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/logger"
+	"time"
+
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
+
+// Repo expires stale payments.
+type Repo interface {
+	ExpirePayments(ctx context.Context, rules []repository.ExpiryRule,
+		limit int) ([]domain.Payment, error)
+}
+
+// Config holds the sweeper settings. A zero TTL disables the expiry of the
+// matching status.
+type Config struct {
+	Interval         time.Duration
+	BatchSize        int
+	PendingTTL       time.Duration
+	FundsReservedTTL time.Duration
+	ProcessingTTL    time.Duration
+}
+
+// rules returns the expiry rules of the enabled TTLs. Payments without funds
+// reserved or not sent to the processor expire, while payments stuck in the
+// processor fail as their outcome is unknown.
+func (c Config) rules() []repository.ExpiryRule {
+	var rules []repository.ExpiryRule
+	add := func(status domain.PaymentStatus, ttl time.Duration,
+		to domain.PaymentStatus, reason string) {
+		if ttl > 0 {
+			rules = append(rules, repository.ExpiryRule{
+				Status: status, TTL: ttl, To: to, Reason: reason,
+			})
+		}
+	}
+	add(domain.StatusPending, c.PendingTTL, domain.StatusExpired,
+		"expired waiting for funds reservation")
+	add(domain.StatusFundsReserved, c.FundsReservedTTL, domain.StatusExpired,
+		"expired waiting for processing")
+	add(domain.StatusProcessing, c.ProcessingTTL, domain.StatusFailed,
+		"processor result not received in time")
+	return rules
+}
+
+// Sweeper periodically expires payments that stayed in a status longer than
+// its TTL. Replicas coordinate through an advisory lock so only one sweeps at
+// a time.
+type Sweeper struct {
+	repository Repo
+	logger     logger.Logger
+	cfg        Config
+}
+
+// NewSweeper creates a new instance of Sweeper.
+func NewSweeper(repository Repo, logger logger.Logger, cfg Config) *Sweeper {
+	cfg.BatchSize = max(cfg.BatchSize, 1)
+	return &Sweeper{
+		repository: repository,
+		logger:     logger,
+		cfg:        cfg,
+	}
+}
+
+// Start runs the sweeper until the context is cancelled.
+func (s *Sweeper) Start(ctx context.Context) {
+	s.logger.Info("starting payment expiry sweeper")
+
+	for {
+		if _, err := s.Run(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error("failed to expire payments", logger.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("payment expiry sweeper stopped due to context cancellation")
+			return
+		case <-time.After(s.cfg.Interval):
+		}
+	}
+}
+
+// Run expires the stale payments in batches and returns how many were
+// expired. It does nothing while another replica holds the sweep lock.
+func (s *Sweeper) Run(ctx context.Context) (int, error) {
+	rules := s.cfg.rules()
+	if len(rules) == 0 {
+		return 0, nil
+	}
+
+	total := 0
+	for ctx.Err() == nil {
+		expired, err := s.repository.ExpirePayments(ctx, rules, s.cfg.BatchSize)
+		if errors.Is(err, repository.ErrExpiryLocked) {
+			s.logger.Debug("payment expiry running on another replica")
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+
+		for _, p := range expired {
+			s.logger.Info("payment expired",
+				logger.String("payment_id", p.PaymentID.String()),
+				logger.String("status", string(p.Status)))
+		}
+		total += len(expired)
+
+		// a rule with a full batch may have more stale payments.
+		if len(expired) < s.cfg.BatchSize {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,100 @@
+package expiry
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/logger"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
+
+// MockRepo returns the configured batches of expired payments in order.
+type MockRepo struct {
+	Batches [][]domain.Payment
+	Err     error
+	Rules   []repository.ExpiryRule
+	Calls   int
+}
+
+func (m *MockRepo) ExpirePayments(ctx context.Context, rules []repository.ExpiryRule,
+	limit int) ([]domain.Payment, error) {
+	m.Rules = rules
+	m.Calls++
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if len(m.Batches) == 0 {
+		return nil, nil
+	}
+	batch := m.Batches[0]
+	m.Batches = m.Batches[1:]
+	return batch, nil
+}
+
+func payments(n int) []domain.Payment {
+	batch := make([]domain.Payment, n)
+	for i := range batch {
+		batch[i] = domain.Payment{PaymentID: uuid.New(), Status: domain.StatusExpired}
+	}
+	return batch
+}
+
+// TestRun_Batches checks that full batches are followed by another sweep
+// and that the rules follow the configured TTLs.
+func TestRun_Batches(t *testing.T) {
+	repo := &MockRepo{Batches: [][]domain.Payment{payments(2), payments(2), payments(1)}}
+	s := NewSweeper(repo, logger.NewNoopLogger(), Config{
+		BatchSize:     2,
+		PendingTTL:    time.Minute,
+		ProcessingTTL: time.Hour,
+	})
+
+	n, err := s.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	require.Equal(t, 3, repo.Calls)
+
+	require.Len(t, repo.Rules, 2)
+	require.Equal(t, domain.StatusPending, repo.Rules[0].Status)
+	require.Equal(t, domain.StatusExpired, repo.Rules[0].To)
+	require.Equal(t, domain.StatusProcessing, repo.Rules[1].Status)
+	require.Equal(t, domain.StatusFailed, repo.Rules[1].To)
+}
+
+// TestRun_Locked checks that a sweep held by another replica is skipped and
+// that other errors are returned.
+func TestRun_Locked(t *testing.T) {
+	repo := &MockRepo{Err: repository.ErrExpiryLocked}
+	s := NewSweeper(repo, logger.NewNoopLogger(), Config{BatchSize: 2, PendingTTL: time.Minute})
+
+	n, err := s.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	repo.Err = errors.New("connection refused")
+	_, err = s.Run(context.Background())
+	require.Error(t, err)
+
+	// without TTLs nothing is swept.
+	repo.Calls = 0
+	_, err = NewSweeper(repo, logger.NewNoopLogger(), Config{BatchSize: 2}).Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, repo.Calls)
+}
+
+// TestRun_ZeroBatchSize checks that a batch size below one is clamped, so a
+// sweep with nothing left to expire ends instead of looping with LIMIT 0.
+func TestRun_ZeroBatchSize(t *testing.T) {
+	repo := &MockRepo{}
+	s := NewSweeper(repo, logger.NewNoopLogger(), Config{PendingTTL: time.Minute})
+
+	n, err := s.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	require.Equal(t, 1, repo.Calls)
+}
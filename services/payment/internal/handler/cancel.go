@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"payment-system/pkg/logger"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
+
+// CancelRequest represents the optional payload for cancelling a payment.
+type CancelRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// CancelPayment handles POST /v1/payments/:payment_id/cancel requests.
+// It cancels a PENDING or FUNDS_RESERVED payment of the user of the
+// x-user-id header and returns the cancelled payment. Cancelling a cancelled
+// payment returns it unchanged, while payments already processed are
+// rejected with StatusConflict.
+func (h *PaymentHandler) CancelPayment(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID, err := userIDFromHeader(c)
+	if err != nil {
+		return err
+	}
+	paymentID, err := uuid.Parse(c.Params("payment_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest,
+			"payment_id invalid")
+	}
+
+	var request CancelRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			h.logger.Error("failed to parse cancel request", logger.Error(err))
+			return fiber.NewError(fiber.StatusBadRequest,
+				"invalid JSON body")
+		}
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		reason = "cancelled by user"
+	}
+
+	// only the owner of the payment can cancel it.
+	payment, err := h.repository.GetPayment(ctx, paymentID)
+	if errors.Is(err, repository.ErrPaymentNotFound) ||
+		(err == nil && payment.UserID != userID) {
+		return fiber.NewError(fiber.StatusNotFound,
+			"payment not found")
+	}
+	if err != nil {
+		h.logger.Error("failed to get payment", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to cancel payment")
+	}
+	if payment.Status == domain.StatusCancelled {
+		return c.Status(fiber.StatusOK).JSON(newPaymentDetailsResponse(payment))
+	}
+
+	cancelled, err := h.repository.CancelPayment(ctx, paymentID, reason)
+	switch {
+	case err == nil:
+		return c.Status(fiber.StatusOK).JSON(newPaymentDetailsResponse(cancelled))
+	case errors.Is(err, domain.ErrInvalidTransition):
+		return fiber.NewError(fiber.StatusConflict,
+			"payment can no longer be cancelled")
+	default:
+		h.logger.Error("failed to cancel payment", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to cancel payment")
+	}
+}
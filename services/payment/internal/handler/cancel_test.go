@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"payment-system/pkg/money"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/order"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
+
+// TestCancelPayment checks that a payment is cancelled only when the state
+// machine allows it, and that repeated cancellations are idempotent.
+func TestCancelPayment(t *testing.T) {
+	app := fiber.New()
+
+	payment := &domain.Payment{
+		PaymentID: uuid.New(),
+		UserID:    1,
+		Amount:    money.MustParse("10.00", "USD"),
+		Status:    domain.StatusFundsReserved,
+	}
+	var reasons []string
+	mockRepo := &MockRepo{
+		GetFunc: func(ctx context.Context, paymentID uuid.UUID) (*domain.Payment, error) {
+			if paymentID == payment.PaymentID {
+				return payment, nil
+			}
+			return nil, repository.ErrPaymentNotFound
+		},
+		CancelFunc: func(ctx context.Context, paymentID uuid.UUID, reason string) (*domain.Payment, error) {
+			if err := payment.Status.ValidateTransition(domain.StatusCancelled); err != nil {
+				return nil, err
+			}
+			reasons = append(reasons, reason)
+			payment.Status = domain.StatusCancelled
+			return payment, nil
+		},
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess), mockRepo, &MockLogger{})
+	app.Post("/payments/:payment_id/cancel", h.CancelPayment)
+
+	cancel := func(paymentID uuid.UUID, userID, body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost,
+			"/payments/"+paymentID.String()+"/cancel", strings.NewReader(body))
+		req.Header.Set("x-user-id", userID)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	require.Equal(t, fiber.StatusNotFound, cancel(payment.PaymentID, "2", "").StatusCode)
+	require.Equal(t, fiber.StatusNotFound, cancel(uuid.New(), "1", "").StatusCode)
+
+	resp := cancel(payment.PaymentID, "1", `{"reason":"changed my mind"}`)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var body PaymentDetailsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, string(domain.StatusCancelled), body.Status)
+	require.Equal(t, []string{"changed my mind"}, reasons)
+
+	// repeated cancellation returns the cancelled payment.
+	require.Equal(t, fiber.StatusOK, cancel(payment.PaymentID, "1", "").StatusCode)
+	require.Len(t, reasons, 1)
+
+	// processed payments cannot be cancelled.
+	payment.Status = domain.StatusCompleted
+	require.Equal(t, fiber.StatusConflict, cancel(payment.PaymentID, "1", "").StatusCode)
+}
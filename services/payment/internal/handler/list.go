@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"payment-system/pkg/logger"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
+
+// ListPaymentsResponse represents a page of payments.
+type ListPaymentsResponse struct {
+	Payments []*PaymentDetailsResponse `json:"payments"`
+	// NextCursor is set when more payments follow, to be sent as cursor to
+	// get the next page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListPayments handles GET /v1/payments requests.
+// It returns the payments of the user of the required x-user-id header
+// matching the query filters, newest first, one page at a time. Query
+// parameters, all optional:
+//   - status, currency, external_order_id: exact matches.
+//   - user_id: must match x-user-id when set.
+//   - created_from, created_to: RFC 3339 created_at range, inclusive and exclusive.
+//   - limit: page size, up to the configured maximum.
+//   - cursor: next_cursor of the previous page.
+func (h *PaymentHandler) ListPayments(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	filter, err := h.parseFilter(c)
+	if err != nil {
+		return err
+	}
+
+	// fetch one more payment than requested to know if there is a next page.
+	limit := filter.Limit
+	filter.Limit++
+	payments, err := h.repository.ListPayments(ctx, filter)
+	if err != nil {
+		h.logger.Error("failed to list payments", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to list payments")
+	}
+
+	response := &ListPaymentsResponse{
+		Payments: make([]*PaymentDetailsResponse, 0, len(payments)),
+	}
+	if len(payments) > limit {
+		payments = payments[:limit]
+		last := payments[limit-1]
+		response.NextCursor = encodeCursor(repository.Cursor{
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+	for i := range payments {
+		response.Payments = append(response.Payments, newPaymentDetailsResponse(&payments[i]))
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// parseFilter builds the repository filter from the request query.
+func (h *PaymentHandler) parseFilter(c *fiber.Ctx) (repository.PaymentFilter, error) {
+	filter := repository.PaymentFilter{
+		Currency: strings.ToUpper(c.Query("currency")),
+		Limit:    h.defaultPageSize,
+	}
+
+	if v := c.Query("status"); v != "" {
+		status, err := domain.ParseStatus(strings.ToUpper(v))
+		if err != nil {
+			return filter, fiber.NewError(fiber.StatusBadRequest, "status invalid")
+		}
+		filter.Status = status
+	}
+
+	// payments of other users are never listed.
+	userID, err := userIDFromHeader(c)
+	if err != nil {
+		return filter, err
+	}
+	if v := c.Query("user_id"); v != "" {
+		queryUserID, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return filter, fiber.NewError(fiber.StatusBadRequest, "user_id invalid")
+		}
+		if uint32(queryUserID) != userID {
+			return filter, fiber.NewError(fiber.StatusForbidden,
+				"user_id does not match x-user-id")
+		}
+	}
+	filter.UserID = &userID
+
+	if v := c.Query("external_order_id"); v != "" {
+		externalOrderID, err := uuid.Parse(v)
+		if err != nil {
+			return filter, fiber.NewError(fiber.StatusBadRequest, "external_order_id invalid")
+		}
+		filter.ExternalOrderID = &externalOrderID
+	}
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"created_from", &filter.CreatedFrom},
+		{"created_to", &filter.CreatedTo},
+	} {
+		v := c.Query(param.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fiber.NewError(fiber.StatusBadRequest, param.name+" invalid")
+		}
+		*param.dest = &t
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > h.maxPageSize {
+			return filter, fiber.NewError(fiber.StatusBadRequest,
+				"limit must be between 1 and "+strconv.Itoa(h.maxPageSize))
+		}
+		filter.Limit = limit
+	}
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil {
+			return filter, fiber.NewError(fiber.StatusBadRequest, "cursor invalid")
+		}
+		filter.After = cursor
+	}
+	return filter, nil
+}
+
+// encodeCursor returns the opaque representation of a cursor.
+func encodeCursor(cursor repository.Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor returned by encodeCursor.
+func decodeCursor(s string) (*repository.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cursor repository.Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == 0 || cursor.CreatedAt.IsZero() {
+		return nil, errors.New("incomplete cursor")
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/order"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
+
+// listPayments emulates the repository listing over the given payments,
+// sorted newest first.
+func listPayments(payments []domain.Payment) func(context.Context,
+	repository.PaymentFilter) ([]domain.Payment, error) {
+	return func(ctx context.Context, filter repository.PaymentFilter) ([]domain.Payment, error) {
+		var page []domain.Payment
+		for _, p := range payments {
+			if filter.UserID != nil && p.UserID != *filter.UserID {
+				continue
+			}
+			if filter.After != nil && !p.CreatedAt.Before(filter.After.CreatedAt) &&
+				!(p.CreatedAt.Equal(filter.After.CreatedAt) && p.ID < filter.After.ID) {
+				continue
+			}
+			if len(page) == filter.Limit {
+				break
+			}
+			page = append(page, p)
+		}
+		return page, nil
+	}
+}
+
+// TestListPayments_Pagination walks all the pages of a listing and checks
+// that every payment is returned once, in order.
+func TestListPayments_Pagination(t *testing.T) {
+	app := fiber.New()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	var payments []domain.Payment
+	for i := 5; i >= 1; i-- {
+		payments = append(payments, domain.Payment{
+			ID:        int64(i),
+			PaymentID: uuid.New(),
+			UserID:    1,
+			// two payments share created_at to exercise the id tie-breaker.
+			CreatedAt: now.Add(time.Duration(min(i, 4)) * time.Second),
+		})
+	}
+	mockRepo := &MockRepo{ListFunc: listPayments(payments)}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess), mockRepo,
+		&MockLogger{}, WithPageSize(2, 10))
+	app.Get("/payments", h.ListPayments)
+
+	var listed []uuid.UUID
+	cursor := ""
+	for pages := 1; ; pages++ {
+		req := httptest.NewRequest(http.MethodGet, "/payments?cursor="+cursor, nil)
+		req.Header.Set("x-user-id", "1")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body ListPaymentsResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		for _, p := range body.Payments {
+			listed = append(listed, p.PaymentID)
+		}
+		if body.NextCursor == "" {
+			require.Equal(t, 3, pages)
+			break
+		}
+		cursor = body.NextCursor
+	}
+
+	require.Len(t, listed, len(payments))
+	for i, p := range payments {
+		require.Equal(t, p.PaymentID, listed[i])
+	}
+}
+
+// TestListPayments_InvalidQuery checks that invalid filters are rejected
+// before querying the repository.
+func TestListPayments_InvalidQuery(t *testing.T) {
+	app := fiber.New()
+
+	mockRepo := &MockRepo{
+		ListFunc: func(ctx context.Context, filter repository.PaymentFilter) ([]domain.Payment, error) {
+			t.Fatal("unexpected repository call")
+			return nil, nil
+		},
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess), mockRepo,
+		&MockLogger{}, WithPageSize(2, 10))
+	app.Get("/payments", h.ListPayments)
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"limit=11", fiber.StatusBadRequest},
+		{"status=unknown", fiber.StatusBadRequest},
+		{"limit=0", fiber.StatusBadRequest},
+		{"cursor=invalid", fiber.StatusBadRequest},
+		{"created_from=yesterday", fiber.StatusBadRequest},
+		{"external_order_id=1", fiber.StatusBadRequest},
+		{"user_id=2", fiber.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/payments?"+tt.query, nil)
+		req.Header.Set("x-user-id", "1")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, tt.status, resp.StatusCode, tt.query)
+	}
+}
+
+// TestListPayments_RequiresUser checks that payments are not listed without
+// the x-user-id header, so the payments of other users are never exposed.
+func TestListPayments_RequiresUser(t *testing.T) {
+	app := fiber.New()
+
+	mockRepo := &MockRepo{
+		ListFunc: func(ctx context.Context, filter repository.PaymentFilter) ([]domain.Payment, error) {
+			t.Fatal("unexpected repository call")
+			return nil, nil
+		},
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess), mockRepo,
+		&MockLogger{}, WithPageSize(2, 10))
+	app.Get("/payments", h.ListPayments)
+
+	for _, query := range []string{"", "user_id=2"} {
+		req := httptest.NewRequest(http.MethodGet, "/payments?"+query, nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, fiber.StatusBadRequest, resp.StatusCode, query)
+	}
+}
@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"payment-system/pkg/logger"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -11,22 +16,48 @@ import (
 	"github.com/walker-16/payment-system/services/payment/internal/repository"
 )
 
+// Default page sizes of the payment listing.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
 // PaymentHandler handles payment HTTP requests.
 type PaymentHandler struct {
-	repository   repository.PaymentRepo
-	orderService order.Service
-	logger       logger.Logger
+	repository      repository.PaymentRepo
+	orderService    order.Service
+	logger          logger.Logger
+	defaultPageSize int
+	maxPageSize     int
+}
+
+// Option configures a PaymentHandler.
+type Option func(*PaymentHandler)
+
+// WithPageSize sets the page size used when listing payments without limit
+// and the largest limit accepted.
+func WithPageSize(defaultSize, maxSize int) Option {
+	return func(h *PaymentHandler) {
+		h.defaultPageSize = defaultSize
+		h.maxPageSize = maxSize
+	}
 }
 
 // NewPaymentHandler creates a new instance of PaymentHandler.
 func NewPaymentHandler(orderService order.Service,
 	repository repository.PaymentRepo,
-	logger logger.Logger) *PaymentHandler {
-	return &PaymentHandler{
-		orderService: orderService,
-		repository:   repository,
-		logger:       logger,
+	logger logger.Logger, opts ...Option) *PaymentHandler {
+	h := &PaymentHandler{
+		orderService:    orderService,
+		repository:      repository,
+		logger:          logger,
+		defaultPageSize: defaultPageSize,
+		maxPageSize:     maxPageSize,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // PaymentRequest represents the payload for creating a new payment.
@@ -39,36 +70,54 @@ type PaymentResponse struct {
 	PaymentID uuid.UUID `json:"payment_id"`
 }
 
+// PaymentDetailsResponse represents the payload returned when reading a payment.
+type PaymentDetailsResponse struct {
+	PaymentID       uuid.UUID `json:"payment_id"`
+	ExternalOrderID uuid.UUID `json:"external_order_id"`
+	Status          string    `json:"status"`
+	Amount          string    `json:"amount"`
+	Currency        string    `json:"currency"`
+	FailureReason   *string   `json:"failure_reason,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// newPaymentDetailsResponse maps a payment to its response payload.
+func newPaymentDetailsResponse(p *domain.Payment) *PaymentDetailsResponse {
+	return &PaymentDetailsResponse{
+		PaymentID:       p.PaymentID,
+		ExternalOrderID: p.ExternalOrderID,
+		Status:          string(p.Status),
+		Amount:          p.Amount.AmountString(),
+		Currency:        string(p.Amount.Currency()),
+		FailureReason:   p.FailureReason,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+	}
+}
+
 // CreatePayment handles POST /v1/payments requests.
 // It validates headers, parses the request body, and returns a confirmation response.
 // Headers required:
 //   - idempotency-key: a unique key to ensure idempotent requests.
 //   - x-user-id: the ID of the user making the request.
+//
+// Idempotency keys are scoped to the user. A request replayed with the same
+// key and body returns the original response, while reusing a key with a
+// different body is rejected with StatusUnprocessableEntity.
 func (h *PaymentHandler) CreatePayment(c *fiber.Ctx) error {
 	ctx := c.UserContext()
 
 	// get idempotency-key field from header.
-	strIdempotencyKey := c.Get("idempotency-key")
-	if strIdempotencyKey == "" {
-		return fiber.NewError(fiber.StatusBadRequest,
-			"idempotency-key header is required")
-	}
-	idempotencyKet, err := uuid.Parse(strIdempotencyKey)
+	idempotencyKet, err := idempotencyKeyFromHeader(c)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest,
-			"idempotency-key invalid")
+		return err
 	}
 
 	// get x-user-id fiel from header.
-	strUserID := c.Get("x-user-id")
-	if strUserID == "" {
-		return fiber.NewError(fiber.StatusBadRequest,
-			"x-user-id header is required")
-	}
-	userID, err := strconv.Atoi(strUserID)
+	userID, err := userIDFromHeader(c)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest,
-			"x-user-id invalid")
+		return err
 	}
 
 	// request body parse.
@@ -86,31 +135,55 @@ func (h *PaymentHandler) CreatePayment(c *fiber.Ctx) error {
 			"external_order_id is required")
 	}
 
+	// replay the original response if the key was already used.
+	requestHash, err := fingerprint(&request)
+	if err != nil {
+		h.logger.Error("failed to fingerprint payment request", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create payment")
+	}
+	existing, err := h.repository.GetByIdempotencyKey(ctx, userID, idempotencyKet)
+	switch {
+	case err == nil:
+		return h.replay(c, existing, requestHash)
+	case !errors.Is(err, repository.ErrPaymentNotFound):
+		h.logger.Error("failed to get payment by idempotency-key", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create payment")
+	}
+
 	// retrieve the order details by external order ID and ensure it
 	// belongs to the specified user ID.
 	order, err := h.orderService.GetOrderByExternalIDForUser(
-		ctx, request.ExternalOrderID, uint32(userID))
+		ctx, request.ExternalOrderID, userID)
 	if err != nil {
 		h.logger.Error("order validation failed", logger.Error(err))
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return orderError(err)
 	}
 
 	// create payment.
-	paymentID := uuid.New()
 	payment := &domain.Payment{
 		PaymentID:       uuid.New(),
 		ExternalOrderID: request.ExternalOrderID,
-		UserID:          uint32(userID),
+		UserID:          userID,
 		IdempotencyKey:  idempotencyKet,
+		RequestHash:     requestHash,
 		Amount:          order.Amount,
-		Currency:        order.Currency,
-		Status:          "PENDING",
+		Status:          domain.StatusPending,
 	}
 
-	// TODO: check idempotency-id.
-
 	// insert payment.
 	err = h.repository.InsertPayment(ctx, payment)
+	if errors.Is(err, repository.ErrDuplicateIdempotencyKey) {
+		// a concurrent request with the same key created the payment first.
+		existing, err := h.repository.GetByIdempotencyKey(ctx, userID, idempotencyKet)
+		if err != nil {
+			h.logger.Error("failed to get payment by idempotency-key", logger.Error(err))
+			return fiber.NewError(fiber.StatusConflict,
+				"a request with the same idempotency-key is in progress")
+		}
+		return h.replay(c, existing, requestHash)
+	}
 	if err != nil {
 		h.logger.Error("failed to insert payment", logger.Error(err))
 		return fiber.NewError(fiber.StatusInternalServerError,
@@ -119,7 +192,117 @@ func (h *PaymentHandler) CreatePayment(c *fiber.Ctx) error {
 
 	// create payment response.
 	response := &PaymentResponse{
-		PaymentID: paymentID,
+		PaymentID: payment.PaymentID,
 	}
 	return c.Status(fiber.StatusAccepted).JSON(response)
 }
+
+// orderError maps an order service error to the HTTP error returned to the
+// client.
+func orderError(err error) error {
+	switch {
+	case errors.Is(err, order.ErrOrderNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "order not found")
+	case errors.Is(err, order.ErrUserMismatch):
+		return fiber.NewError(fiber.StatusForbidden,
+			"order does not belong to the user")
+	case errors.Is(err, order.ErrUnavailable):
+		return fiber.NewError(fiber.StatusServiceUnavailable,
+			"order service unavailable, try again later")
+	case errors.Is(err, order.ErrInvalidOrder):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	default:
+		return fiber.NewError(fiber.StatusBadGateway, "failed to validate order")
+	}
+}
+
+// GetPayment handles GET /v1/payments/:payment_id requests.
+// It returns the payment status and details when the payment belongs to the
+// user of the x-user-id header. Payments of other users are reported as not
+// found so their existence is not disclosed.
+func (h *PaymentHandler) GetPayment(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID, err := userIDFromHeader(c)
+	if err != nil {
+		return err
+	}
+
+	paymentID, err := uuid.Parse(c.Params("payment_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest,
+			"payment_id invalid")
+	}
+
+	payment, err := h.repository.GetPayment(ctx, paymentID)
+	if errors.Is(err, repository.ErrPaymentNotFound) ||
+		(err == nil && payment.UserID != userID) {
+		return fiber.NewError(fiber.StatusNotFound,
+			"payment not found")
+	}
+	if err != nil {
+		h.logger.Error("failed to get payment", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to get payment")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newPaymentDetailsResponse(payment))
+}
+
+// idempotencyKeyFromHeader returns the key of the required idempotency-key
+// header.
+func idempotencyKeyFromHeader(c *fiber.Ctx) (uuid.UUID, error) {
+	strIdempotencyKey := c.Get("idempotency-key")
+	if strIdempotencyKey == "" {
+		return uuid.Nil, fiber.NewError(fiber.StatusBadRequest,
+			"idempotency-key header is required")
+	}
+	idempotencyKey, err := uuid.Parse(strIdempotencyKey)
+	if err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusBadRequest,
+			"idempotency-key invalid")
+	}
+	return idempotencyKey, nil
+}
+
+// userIDFromHeader returns the ID of the user making the request, taken from
+// the required x-user-id header.
+func userIDFromHeader(c *fiber.Ctx) (uint32, error) {
+	strUserID := c.Get("x-user-id")
+	if strUserID == "" {
+		return 0, fiber.NewError(fiber.StatusBadRequest,
+			"x-user-id header is required")
+	}
+	userID, err := strconv.ParseUint(strUserID, 10, 32)
+	if err != nil {
+		return 0, fiber.NewError(fiber.StatusBadRequest,
+			"x-user-id invalid")
+	}
+	return uint32(userID), nil
+}
+
+// replay responds to a request whose idempotency key already created the
+// given payment, provided the request body is the same.
+func (h *PaymentHandler) replay(c *fiber.Ctx, payment *domain.Payment,
+	requestHash string) error {
+	if payment.RequestHash != requestHash {
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"idempotency-key already used with a different request")
+	}
+
+	response := &PaymentResponse{
+		PaymentID: payment.PaymentID,
+	}
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// fingerprint returns the hex encoded SHA-256 of the canonical JSON encoding
+// of the request, so formatting differences do not change it.
+func fingerprint(request any) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
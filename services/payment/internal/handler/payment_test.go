@@ -3,17 +3,23 @@ package handler
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"payment-system/pkg/logger"
+	"payment-system/pkg/money"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/test-go/testify/require"
 	"github.com/walker-16/payment-system/services/payment/internal/domain"
 	"github.com/walker-16/payment-system/services/payment/internal/order"
+	"github.com/walker-16/payment-system/services/payment/internal/order/ordertest"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
 )
 
 type MockLogger struct{}
@@ -26,7 +32,14 @@ func (l *MockLogger) Fatal(msg string, args ...any)  {}
 func (l *MockLogger) With(args ...any) logger.Logger { return l }
 
 type MockRepo struct {
-	InsertFunc func(ctx context.Context, p *domain.Payment) error
+	InsertFunc              func(ctx context.Context, p *domain.Payment) error
+	GetByIdempotencyKeyFunc func(ctx context.Context, userID uint32, key uuid.UUID) (*domain.Payment, error)
+	GetFunc                 func(ctx context.Context, paymentID uuid.UUID) (*domain.Payment, error)
+	ListFunc                func(ctx context.Context, filter repository.PaymentFilter) ([]domain.Payment, error)
+	UpdateStatusFunc        func(ctx context.Context, paymentID uuid.UUID, change domain.StatusChange) (*domain.Payment, error)
+	CreateRefundFunc        func(ctx context.Context, refund *domain.Refund) (*domain.Payment, error)
+	GetRefundFunc           func(ctx context.Context, paymentID, key uuid.UUID) (*domain.Refund, error)
+	CancelFunc              func(ctx context.Context, paymentID uuid.UUID, reason string) (*domain.Payment, error)
 }
 
 func (m *MockRepo) InsertPayment(ctx context.Context, p *domain.Payment) error {
@@ -36,6 +49,80 @@ func (m *MockRepo) InsertPayment(ctx context.Context, p *domain.Payment) error {
 	return nil
 }
 
+func (m *MockRepo) GetByIdempotencyKey(ctx context.Context, userID uint32,
+	key uuid.UUID) (*domain.Payment, error) {
+	if m.GetByIdempotencyKeyFunc != nil {
+		return m.GetByIdempotencyKeyFunc(ctx, userID, key)
+	}
+	return nil, repository.ErrPaymentNotFound
+}
+
+func (m *MockRepo) GetPayment(ctx context.Context, paymentID uuid.UUID) (*domain.Payment, error) {
+	if m.GetFunc != nil {
+		return m.GetFunc(ctx, paymentID)
+	}
+	return nil, repository.ErrPaymentNotFound
+}
+
+func (m *MockRepo) ListPayments(ctx context.Context,
+	filter repository.PaymentFilter) ([]domain.Payment, error) {
+	if m.ListFunc != nil {
+		return m.ListFunc(ctx, filter)
+	}
+	return nil, nil
+}
+
+func (m *MockRepo) UpdateStatus(ctx context.Context, paymentID uuid.UUID,
+	change domain.StatusChange) (*domain.Payment, error) {
+	if m.UpdateStatusFunc != nil {
+		return m.UpdateStatusFunc(ctx, paymentID, change)
+	}
+	return nil, repository.ErrPaymentNotFound
+}
+
+func (m *MockRepo) CreateRefund(ctx context.Context, refund *domain.Refund) (*domain.Payment, error) {
+	if m.CreateRefundFunc != nil {
+		return m.CreateRefundFunc(ctx, refund)
+	}
+	return nil, repository.ErrPaymentNotRefundable
+}
+
+func (m *MockRepo) GetRefundByIdempotencyKey(ctx context.Context,
+	paymentID, key uuid.UUID) (*domain.Refund, error) {
+	if m.GetRefundFunc != nil {
+		return m.GetRefundFunc(ctx, paymentID, key)
+	}
+	return nil, repository.ErrRefundNotFound
+}
+
+func (m *MockRepo) CancelPayment(ctx context.Context, paymentID uuid.UUID,
+	reason string) (*domain.Payment, error) {
+	if m.CancelFunc != nil {
+		return m.CancelFunc(ctx, paymentID, reason)
+	}
+	return nil, repository.ErrPaymentNotFound
+}
+
+// newPaymentRequest builds a create payment request for the given order, key
+// and user.
+func newPaymentRequest(externalOrderID, idempotencyKey uuid.UUID, userID string) *http.Request {
+	reqBody, _ := json.Marshal(map[string]string{
+		"external_order_id": externalOrderID.String(),
+	})
+	req := httptest.NewRequest(http.MethodPost, "/payments", bytes.NewReader(reqBody))
+	req.Header.Set("idempotency-key", idempotencyKey.String())
+	req.Header.Set("x-user-id", userID)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// decodePaymentResponse decodes the body of a create payment response.
+func decodePaymentResponse(t *testing.T, resp *http.Response) PaymentResponse {
+	var body PaymentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
 // TestCreatePayment_Success verifies that a valid request with all required headers
 // and a correct external order ID creates a payment successfully and returns StatusAccepted.
 func TestCreatePayment_Success(t *testing.T) {
@@ -63,6 +150,124 @@ func TestCreatePayment_Success(t *testing.T) {
 	require.Equal(t, fiber.StatusAccepted, resp.StatusCode)
 }
 
+// TestCreatePayment_ResponsePaymentID checks that the response returns the ID
+// of the stored payment together with its request fingerprint.
+func TestCreatePayment_ResponsePaymentID(t *testing.T) {
+	app := fiber.New()
+
+	var inserted *domain.Payment
+	mockRepo := &MockRepo{
+		InsertFunc: func(ctx context.Context, p *domain.Payment) error {
+			inserted = p
+			return nil
+		},
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess), mockRepo, &MockLogger{})
+	app.Post("/payments", h.CreatePayment)
+
+	resp, err := app.Test(newPaymentRequest(uuid.New(), uuid.New(), "1"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+
+	require.NotNil(t, inserted)
+	require.NotEmpty(t, inserted.RequestHash)
+	require.Equal(t, inserted.PaymentID, decodePaymentResponse(t, resp).PaymentID)
+}
+
+// TestCreatePayment_IdempotentReplay checks that replaying a request with the
+// same idempotency-key and body returns the original payment without
+// creating a new one, and that a different body is rejected.
+func TestCreatePayment_IdempotentReplay(t *testing.T) {
+	app := fiber.New()
+
+	payments := map[uuid.UUID]*domain.Payment{}
+	mockRepo := &MockRepo{
+		InsertFunc: func(ctx context.Context, p *domain.Payment) error {
+			payments[p.IdempotencyKey] = p
+			return nil
+		},
+		GetByIdempotencyKeyFunc: func(ctx context.Context, userID uint32,
+			key uuid.UUID) (*domain.Payment, error) {
+			if p, ok := payments[key]; ok && p.UserID == userID {
+				return p, nil
+			}
+			return nil, repository.ErrPaymentNotFound
+		},
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess), mockRepo, &MockLogger{})
+	app.Post("/payments", h.CreatePayment)
+
+	externalOrderID, key := uuid.New(), uuid.New()
+	resp, err := app.Test(newPaymentRequest(externalOrderID, key, "1"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+	original := decodePaymentResponse(t, resp)
+
+	// same key and body replays the original response.
+	resp, err = app.Test(newPaymentRequest(externalOrderID, key, "1"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+	require.Equal(t, original, decodePaymentResponse(t, resp))
+	require.Len(t, payments, 1)
+
+	// same key with a different body is rejected.
+	resp, err = app.Test(newPaymentRequest(uuid.New(), key, "1"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+// TestFingerprint_MatchesBackfill checks that payment requests are
+// fingerprinted like migration 016 backfills the payments created before
+// fingerprints were stored, so retries of those requests are replayed.
+func TestFingerprint_MatchesBackfill(t *testing.T) {
+	orderID := uuid.New()
+	hash, err := fingerprint(&PaymentRequest{ExternalOrderID: orderID})
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte(`{"external_order_id":"` + orderID.String() + `"}`))
+	require.Equal(t, hex.EncodeToString(sum[:]), hash)
+}
+
+// TestCreatePayment_ConcurrentDuplicate checks that a request losing the race
+// to insert a payment with the same idempotency-key replays the winner, and
+// returns StatusConflict when the winner cannot be read.
+func TestCreatePayment_ConcurrentDuplicate(t *testing.T) {
+	app := fiber.New()
+
+	var winner *domain.Payment
+	mockRepo := &MockRepo{
+		InsertFunc: func(ctx context.Context, p *domain.Payment) error {
+			winner = &domain.Payment{PaymentID: uuid.New(), RequestHash: p.RequestHash}
+			return repository.ErrDuplicateIdempotencyKey
+		},
+		GetByIdempotencyKeyFunc: func(ctx context.Context, userID uint32,
+			key uuid.UUID) (*domain.Payment, error) {
+			if winner != nil {
+				return winner, nil
+			}
+			return nil, repository.ErrPaymentNotFound
+		},
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess), mockRepo, &MockLogger{})
+	app.Post("/payments", h.CreatePayment)
+
+	resp, err := app.Test(newPaymentRequest(uuid.New(), uuid.New(), "1"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+	require.Equal(t, winner.PaymentID, decodePaymentResponse(t, resp).PaymentID)
+
+	mockRepo.InsertFunc = func(ctx context.Context, p *domain.Payment) error {
+		return repository.ErrDuplicateIdempotencyKey
+	}
+	mockRepo.GetByIdempotencyKeyFunc = func(ctx context.Context, userID uint32,
+		key uuid.UUID) (*domain.Payment, error) {
+		return nil, repository.ErrPaymentNotFound
+	}
+	resp, err = app.Test(newPaymentRequest(uuid.New(), uuid.New(), "1"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusConflict, resp.StatusCode)
+}
+
 // TestCreatePayment_MissingHeaders checks that if required headers are missing,
 // the handler returns StatusBadRequest instead of proceeding.
 func TestCreatePayment_MissingHeaders(t *testing.T) {
@@ -82,27 +287,106 @@ func TestCreatePayment_MissingHeaders(t *testing.T) {
 	}
 }
 
-// TestCreatePayment_OrderServiceError simulates an error returned by the order service.
-// The handler should catch the error and return StatusBadRequest.
+// TestCreatePayment_OrderServiceError checks that each order service error is
+// mapped to its own status.
 func TestCreatePayment_OrderServiceError(t *testing.T) {
+	tests := []struct {
+		name         string
+		responseType order.MockResponseType
+		status       int
+	}{
+		{"not found", order.MockErrorNotFound, fiber.StatusNotFound},
+		{"user mismatch", order.MockErrorUserMismatch, fiber.StatusForbidden},
+		{"unavailable", order.MockErrorInternal, fiber.StatusServiceUnavailable},
+		{"invalid order", order.MockErrorBadRequest, fiber.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			h := NewPaymentHandler(order.NewMockOrderService(tt.responseType),
+				&MockRepo{}, &MockLogger{})
+			app.Post("/payments", h.CreatePayment)
+
+			resp, _ := app.Test(newPaymentRequest(uuid.New(), uuid.New(), "1"))
+
+			require.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+// TestCreatePayment_HTTPOrderService creates a payment for an order served by
+// the fake Order Service.
+func TestCreatePayment_HTTPOrderService(t *testing.T) {
+	server := ordertest.NewServer()
+	defer server.Close()
+	orderID := uuid.New()
+	server.AddOrder(ordertest.Order{OrderID: orderID, UserID: 1, ServiceName: "Service A",
+		Amount: "12.30", Currency: "EUR"})
+
+	var inserted *domain.Payment
+	mockRepo := &MockRepo{
+		InsertFunc: func(ctx context.Context, p *domain.Payment) error {
+			inserted = p
+			return nil
+		},
+	}
+	orderService := order.NewHTTPService(order.HTTPConfig{BaseURL: server.URL, Timeout: time.Second})
 	app := fiber.New()
+	app.Post("/payments", NewPaymentHandler(orderService, mockRepo, &MockLogger{}).CreatePayment)
 
-	mockRepo := &MockRepo{}
-	mockOrder := order.NewMockOrderService(order.MockErrorInternal)
+	resp, _ := app.Test(newPaymentRequest(orderID, uuid.New(), "1"))
 
-	h := NewPaymentHandler(mockOrder, mockRepo, &MockLogger{})
+	require.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+	require.NotNil(t, inserted)
+	require.Equal(t, "12.30 EUR", inserted.Amount.String())
+}
 
-	app.Post("/payments", h.CreatePayment)
+// TestGetPayment checks that the owner of a payment can read its details and
+// that other users, unknown and invalid IDs get the matching error status.
+func TestGetPayment(t *testing.T) {
+	app := fiber.New()
 
-	reqBody, _ := json.Marshal(map[string]string{
-		"external_order_id": uuid.New().String(),
-	})
-	req := httptest.NewRequest(http.MethodPost, "/payments", bytes.NewReader(reqBody))
-	req.Header.Set("idempotency-key", uuid.New().String())
-	req.Header.Set("x-user-id", "1")
-	resp, _ := app.Test(req)
+	reason := "insufficient funds"
+	payment := &domain.Payment{
+		PaymentID:       uuid.New(),
+		ExternalOrderID: uuid.New(),
+		UserID:          1,
+		Amount:          money.MustParse("99.99", "USD"),
+		Status:          domain.StatusFailed,
+		FailureReason:   &reason,
+		CreatedAt:       time.Now().UTC().Truncate(time.Second),
+		UpdatedAt:       time.Now().UTC().Truncate(time.Second),
+	}
+	mockRepo := &MockRepo{
+		GetFunc: func(ctx context.Context, paymentID uuid.UUID) (*domain.Payment, error) {
+			if paymentID == payment.PaymentID {
+				return payment, nil
+			}
+			return nil, repository.ErrPaymentNotFound
+		},
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess), mockRepo, &MockLogger{})
+	app.Get("/payments/:payment_id", h.GetPayment)
 
-	if resp.StatusCode != fiber.StatusBadRequest {
-		t.Errorf("expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+	get := func(paymentID, userID string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/payments/"+paymentID, nil)
+		if userID != "" {
+			req.Header.Set("x-user-id", userID)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
 	}
+
+	resp := get(payment.PaymentID.String(), "1")
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var body PaymentDetailsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, *newPaymentDetailsResponse(payment), body)
+
+	require.Equal(t, fiber.StatusNotFound, get(payment.PaymentID.String(), "2").StatusCode)
+	require.Equal(t, fiber.StatusNotFound, get(uuid.New().String(), "1").StatusCode)
+	require.Equal(t, fiber.StatusBadRequest, get("invalid", "1").StatusCode)
+	require.Equal(t, fiber.StatusBadRequest, get(payment.PaymentID.String(), "").StatusCode)
 }
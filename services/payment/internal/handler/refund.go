@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"payment-system/pkg/logger"
+	"payment-system/pkg/money"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
+
+// RefundRequest represents the payload for refunding a payment.
+type RefundRequest struct {
+	// Amount is the decimal amount to refund in the payment currency. The
+	// remaining refundable amount is refunded when empty.
+	Amount string `json:"amount,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// RefundResponse represents the payload returned after requesting a refund.
+type RefundResponse struct {
+	RefundID      uuid.UUID `json:"refund_id"`
+	PaymentID     uuid.UUID `json:"payment_id"`
+	Amount        string    `json:"amount"`
+	Currency      string    `json:"currency"`
+	Status        string    `json:"status"`
+	PaymentStatus string    `json:"payment_status,omitempty"`
+}
+
+// newRefundResponse maps a refund to its response payload.
+func newRefundResponse(r *domain.Refund) *RefundResponse {
+	return &RefundResponse{
+		RefundID:  r.RefundID,
+		PaymentID: r.PaymentID,
+		Amount:    r.Amount.AmountString(),
+		Currency:  string(r.Amount.Currency()),
+		Status:    r.Status,
+	}
+}
+
+// CreateRefund handles POST /v1/payments/:payment_id/refunds requests.
+// It requests a full or partial refund of a completed payment of the user.
+// Headers required:
+//   - idempotency-key: a unique key per payment to ensure idempotent requests.
+//   - x-user-id: the ID of the user making the request.
+//
+// Refunds exceeding the amount left to refund are rejected with
+// StatusUnprocessableEntity, and refunds of payments that are not completed
+// with StatusConflict.
+func (h *PaymentHandler) CreateRefund(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	idempotencyKey, err := idempotencyKeyFromHeader(c)
+	if err != nil {
+		return err
+	}
+	userID, err := userIDFromHeader(c)
+	if err != nil {
+		return err
+	}
+	paymentID, err := uuid.Parse(c.Params("payment_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest,
+			"payment_id invalid")
+	}
+
+	var request RefundRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			h.logger.Error("failed to parse refund request", logger.Error(err))
+			return fiber.NewError(fiber.StatusBadRequest,
+				"invalid JSON body")
+		}
+	}
+
+	// only the owner of the payment can refund it.
+	payment, err := h.repository.GetPayment(ctx, paymentID)
+	if errors.Is(err, repository.ErrPaymentNotFound) ||
+		(err == nil && payment.UserID != userID) {
+		return fiber.NewError(fiber.StatusNotFound,
+			"payment not found")
+	}
+	if err != nil {
+		h.logger.Error("failed to get payment", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create refund")
+	}
+
+	// replay the original response if the key was already used.
+	requestHash, err := fingerprint(&request)
+	if err != nil {
+		h.logger.Error("failed to fingerprint refund request", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create refund")
+	}
+	existing, err := h.repository.GetRefundByIdempotencyKey(ctx, paymentID, idempotencyKey)
+	switch {
+	case err == nil:
+		return h.replayRefund(c, existing, requestHash)
+	case !errors.Is(err, repository.ErrRefundNotFound):
+		h.logger.Error("failed to get refund by idempotency-key", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create refund")
+	}
+
+	refund := &domain.Refund{
+		PaymentID:      paymentID,
+		UserID:         userID,
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+	}
+	if request.Amount != "" {
+		currency := payment.Amount.Currency()
+		amount, err := money.Parse(request.Amount, string(currency))
+		if errors.Is(err, money.ErrTooPrecise) {
+			return fiber.NewError(fiber.StatusBadRequest,
+				fmt.Sprintf("amount must have at most %d decimals in %s",
+					currency.MinorUnits(), currency))
+		}
+		if err != nil || !amount.IsPositive() {
+			return fiber.NewError(fiber.StatusBadRequest,
+				"amount must be a positive decimal")
+		}
+		refund.Amount = amount
+	}
+	if reason := strings.TrimSpace(request.Reason); reason != "" {
+		refund.Reason = &reason
+	}
+
+	updated, err := h.repository.CreateRefund(ctx, refund)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrDuplicateIdempotencyKey):
+		// a concurrent request with the same key created the refund first.
+		existing, err := h.repository.GetRefundByIdempotencyKey(ctx, paymentID, idempotencyKey)
+		if err != nil {
+			h.logger.Error("failed to get refund by idempotency-key", logger.Error(err))
+			return fiber.NewError(fiber.StatusConflict,
+				"a request with the same idempotency-key is in progress")
+		}
+		return h.replayRefund(c, existing, requestHash)
+	case errors.Is(err, repository.ErrRefundExceedsAmount):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, repository.ErrPaymentNotRefundable),
+		errors.Is(err, domain.ErrInvalidTransition):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	default:
+		h.logger.Error("failed to create refund", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create refund")
+	}
+
+	response := newRefundResponse(refund)
+	response.PaymentStatus = string(updated.Status)
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// replayRefund responds to a request whose idempotency key already created
+// the given refund, provided the request body is the same.
+func (h *PaymentHandler) replayRefund(c *fiber.Ctx, refund *domain.Refund,
+	requestHash string) error {
+	if refund.RequestHash != requestHash {
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"idempotency-key already used with a different request")
+	}
+	return c.Status(fiber.StatusAccepted).JSON(newRefundResponse(refund))
+}
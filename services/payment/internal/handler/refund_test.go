@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"payment-system/pkg/money"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+	"github.com/walker-16/payment-system/services/payment/internal/order"
+	"github.com/walker-16/payment-system/services/payment/internal/repository"
+)
+
+// newRefundRepo returns a MockRepo holding the given payment and applying
+// refunds to it with the repository cap, recording them by idempotency key.
+func newRefundRepo(payment *domain.Payment) *MockRepo {
+	refunds := map[uuid.UUID]*domain.Refund{}
+	refunded := money.Zero(payment.Amount.Currency())
+	return &MockRepo{
+		GetFunc: func(ctx context.Context, paymentID uuid.UUID) (*domain.Payment, error) {
+			if paymentID == payment.PaymentID {
+				return payment, nil
+			}
+			return nil, repository.ErrPaymentNotFound
+		},
+		GetRefundFunc: func(ctx context.Context, paymentID, key uuid.UUID) (*domain.Refund, error) {
+			if r, ok := refunds[key]; ok {
+				return r, nil
+			}
+			return nil, repository.ErrRefundNotFound
+		},
+		CreateRefundFunc: func(ctx context.Context, refund *domain.Refund) (*domain.Payment, error) {
+			if !payment.Status.IsRefundable() {
+				return nil, repository.ErrPaymentNotRefundable
+			}
+			remaining, _ := payment.Amount.Sub(refunded)
+			if refund.Amount.IsZero() {
+				refund.Amount = remaining
+			}
+			left, _ := remaining.Sub(refund.Amount)
+			if left.IsNegative() {
+				return nil, repository.ErrRefundExceedsAmount
+			}
+			refunded, _ = refunded.Add(refund.Amount)
+			refund.RefundID = uuid.New()
+			refund.Status = domain.RefundStatusRequested
+			refunds[refund.IdempotencyKey] = refund
+
+			payment.Status = domain.StatusPartiallyRefunded
+			if left.IsZero() {
+				payment.Status = domain.StatusRefunded
+			}
+			return payment, nil
+		},
+	}
+}
+
+// postRefund sends a refund request and decodes the response on success.
+func postRefund(t *testing.T, app *fiber.App, paymentID uuid.UUID, key uuid.UUID,
+	userID string, body map[string]string) (int, RefundResponse) {
+	reqBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost,
+		fmt.Sprintf("/payments/%s/refunds", paymentID), bytes.NewReader(reqBody))
+	req.Header.Set("idempotency-key", key.String())
+	req.Header.Set("x-user-id", userID)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	var response RefundResponse
+	if resp.StatusCode == fiber.StatusAccepted {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	}
+	return resp.StatusCode, response
+}
+
+// TestCreateRefund_PartialThenFull checks that partial refunds move the
+// payment to PARTIALLY_REFUNDED, that refunds are capped to the payment
+// amount, and that a refund without amount refunds the rest.
+func TestCreateRefund_PartialThenFull(t *testing.T) {
+	app := fiber.New()
+	payment := &domain.Payment{
+		PaymentID: uuid.New(),
+		UserID:    1,
+		Amount:    money.MustParse("100.00", "USD"),
+		Status:    domain.StatusCompleted,
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess),
+		newRefundRepo(payment), &MockLogger{})
+	app.Post("/payments/:payment_id/refunds", h.CreateRefund)
+
+	status, resp := postRefund(t, app, payment.PaymentID, uuid.New(), "1",
+		map[string]string{"amount": "30", "reason": "damaged"})
+	require.Equal(t, fiber.StatusAccepted, status)
+	require.Equal(t, "30.00", resp.Amount)
+	require.Equal(t, "USD", resp.Currency)
+	require.Equal(t, string(domain.StatusPartiallyRefunded), resp.PaymentStatus)
+
+	status, _ = postRefund(t, app, payment.PaymentID, uuid.New(), "1",
+		map[string]string{"amount": "70.01"})
+	require.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, resp = postRefund(t, app, payment.PaymentID, uuid.New(), "1", nil)
+	require.Equal(t, fiber.StatusAccepted, status)
+	require.Equal(t, "70.00", resp.Amount)
+	require.Equal(t, string(domain.StatusRefunded), resp.PaymentStatus)
+
+	status, _ = postRefund(t, app, payment.PaymentID, uuid.New(), "1", nil)
+	require.Equal(t, fiber.StatusConflict, status)
+}
+
+// TestCreateRefund_Idempotent checks that a replayed refund returns the
+// original refund and a reused key with a different body is rejected.
+func TestCreateRefund_Idempotent(t *testing.T) {
+	app := fiber.New()
+	payment := &domain.Payment{
+		PaymentID: uuid.New(),
+		UserID:    1,
+		Amount:    money.MustParse("100.00", "USD"),
+		Status:    domain.StatusCompleted,
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess),
+		newRefundRepo(payment), &MockLogger{})
+	app.Post("/payments/:payment_id/refunds", h.CreateRefund)
+
+	key := uuid.New()
+	status, original := postRefund(t, app, payment.PaymentID, key, "1",
+		map[string]string{"amount": "10"})
+	require.Equal(t, fiber.StatusAccepted, status)
+
+	status, replayed := postRefund(t, app, payment.PaymentID, key, "1",
+		map[string]string{"amount": "10"})
+	require.Equal(t, fiber.StatusAccepted, status)
+	require.Equal(t, original.RefundID, replayed.RefundID)
+
+	status, _ = postRefund(t, app, payment.PaymentID, key, "1",
+		map[string]string{"amount": "20"})
+	require.Equal(t, fiber.StatusUnprocessableEntity, status)
+}
+
+// TestCreateRefund_Rejected checks the validation of refund requests.
+func TestCreateRefund_Rejected(t *testing.T) {
+	app := fiber.New()
+	payment := &domain.Payment{
+		PaymentID: uuid.New(),
+		UserID:    1,
+		Amount:    money.MustParse("100.00", "USD"),
+		Status:    domain.StatusPending,
+	}
+	h := NewPaymentHandler(order.NewMockOrderService(order.MockSuccess),
+		newRefundRepo(payment), &MockLogger{})
+	app.Post("/payments/:payment_id/refunds", h.CreateRefund)
+
+	status, _ := postRefund(t, app, payment.PaymentID, uuid.New(), "1", nil)
+	require.Equal(t, fiber.StatusConflict, status)
+	status, _ = postRefund(t, app, payment.PaymentID, uuid.New(), "2", nil)
+	require.Equal(t, fiber.StatusNotFound, status)
+	status, _ = postRefund(t, app, payment.PaymentID, uuid.New(), "1",
+		map[string]string{"amount": "-5"})
+	require.Equal(t, fiber.StatusBadRequest, status)
+	// amounts are not rounded up to a larger refund than requested.
+	status, _ = postRefund(t, app, payment.PaymentID, uuid.New(), "1",
+		map[string]string{"amount": "10.005"})
+	require.Equal(t, fiber.StatusBadRequest, status)
+}
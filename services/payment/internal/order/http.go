@@ -0,0 +1,149 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"payment-system/pkg/money"
+	"payment-system/pkg/retry"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HTTPConfig holds the settings of the Order Service HTTP client.
+type HTTPConfig struct {
+	// BaseURL is the address of the Order Service, e.g. http://orders:8080.
+	BaseURL string
+	// Timeout bounds each attempt, including reading the response body.
+	Timeout time.Duration
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// orderResponse is the body returned by GET /orders/{orderId}.
+type orderResponse struct {
+	OrderID     uuid.UUID `json:"order_id"`
+	UserID      uint32    `json:"user_id"`
+	ServiceName string    `json:"service_name"`
+	Amount      string    `json:"amount"`
+	Currency    string    `json:"currency"`
+	BankAccount string    `json:"bank_account"`
+	BankCode    string    `json:"bank_code"`
+}
+
+// HTTPService retrieves orders from the Order Service over HTTP. Requests
+// that fail in transport or are answered with a 5xx status are retried with
+// backoff, while 4xx responses are mapped to the matching error and returned
+// right away.
+type HTTPService struct {
+	client *http.Client
+	cfg    HTTPConfig
+}
+
+// NewHTTPService creates a new instance of HTTPService.
+func NewHTTPService(cfg HTTPConfig) *HTTPService {
+	return &HTTPService{
+		client: &http.Client{Timeout: cfg.Timeout},
+		cfg:    cfg,
+	}
+}
+
+// GetOrderByExternalIDForUser calls GET /orders/{orderId} and ensures the
+// order belongs to the user.
+func (s *HTTPService) GetOrderByExternalIDForUser(ctx context.Context,
+	externalOrderID uuid.UUID, userID uint32) (*Order, error) {
+	endpoint, err := url.JoinPath(s.cfg.BaseURL, "orders", externalOrderID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build order url: %w", err)
+	}
+
+	var response *orderResponse
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		response, retryable, err = s.get(ctx, endpoint, userID)
+		if !retryable || attempt >= s.cfg.MaxRetries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrUnavailable, ctx.Err())
+		case <-time.After(retry.Backoff(attempt+1, s.cfg.BaseBackoff, s.cfg.MaxBackoff)):
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if response.UserID != userID {
+		return nil, ErrUserMismatch
+	}
+	amount, err := money.Parse(response.Amount, response.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOrder, err)
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidOrder)
+	}
+
+	return &Order{
+		ExternalID:  externalOrderID,
+		ServiceName: response.ServiceName,
+		Amount:      amount,
+		BankAccount: response.BankAccount,
+		BankCode:    response.BankCode,
+		UserID:      response.UserID,
+	}, nil
+}
+
+// get performs a single request and reports whether it may be retried.
+func (s *HTTPService) get(ctx context.Context, endpoint string,
+	userID uint32) (*orderResponse, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create order request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("x-user-id", strconv.FormatUint(uint64(userID), 10))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// transport errors, such as timeouts or dropped connections, are
+		// retried unless the request was canceled.
+		return nil, ctx.Err() == nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, ErrOrderNotFound
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, false, ErrUserMismatch
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, true, fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	default:
+		return nil, false, fmt.Errorf("%w: status %d: %s", ErrInvalidOrder,
+			resp.StatusCode, readMessage(resp.Body))
+	}
+
+	var response orderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, false, fmt.Errorf("%w: failed to decode order: %w", ErrUnavailable, err)
+	}
+	return &response, false, nil
+}
+
+// readMessage returns the beginning of an error response body.
+func readMessage(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, 512))
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,109 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/payment/internal/order/ordertest"
+)
+
+func newTestService(baseURL string) *HTTPService {
+	return NewHTTPService(HTTPConfig{
+		BaseURL:     baseURL,
+		Timeout:     time.Second,
+		MaxRetries:  2,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+	})
+}
+
+// TestHTTPService_GetOrder checks that an order is returned for its owner and
+// that missing, foreign and invalid orders return the matching error.
+func TestHTTPService_GetOrder(t *testing.T) {
+	server := ordertest.NewServer()
+	defer server.Close()
+	service := newTestService(server.URL)
+
+	o := ordertest.Order{OrderID: uuid.New(), UserID: 1, ServiceName: "Service A",
+		Amount: "99.99", Currency: "USD", BankAccount: "123456789", BankCode: "XY"}
+	server.AddOrder(o)
+
+	got, err := service.GetOrderByExternalIDForUser(context.Background(), o.OrderID, 1)
+	require.NoError(t, err)
+	require.Equal(t, o.OrderID, got.ExternalID)
+	require.Equal(t, "99.99 USD", got.Amount.String())
+	require.Equal(t, "Service A", got.ServiceName)
+
+	_, err = service.GetOrderByExternalIDForUser(context.Background(), o.OrderID, 2)
+	require.True(t, errors.Is(err, ErrUserMismatch))
+
+	_, err = service.GetOrderByExternalIDForUser(context.Background(), uuid.New(), 1)
+	require.True(t, errors.Is(err, ErrOrderNotFound))
+
+	invalid := ordertest.Order{OrderID: uuid.New(), UserID: 1, Amount: "10", Currency: "XXX"}
+	server.AddOrder(invalid)
+	_, err = service.GetOrderByExternalIDForUser(context.Background(), invalid.OrderID, 1)
+	require.True(t, errors.Is(err, ErrInvalidOrder))
+}
+
+// TestHTTPService_Retries checks that 5xx responses are retried up to
+// MaxRetries and that 4xx responses are not.
+func TestHTTPService_Retries(t *testing.T) {
+	server := ordertest.NewServer()
+	defer server.Close()
+	service := newTestService(server.URL)
+
+	o := ordertest.Order{OrderID: uuid.New(), UserID: 1, Amount: "5.00", Currency: "EUR"}
+	server.AddOrder(o)
+
+	// recovers within the retries.
+	server.FailNext(http.StatusServiceUnavailable, http.StatusBadGateway)
+	_, err := service.GetOrderByExternalIDForUser(context.Background(), o.OrderID, 1)
+	require.NoError(t, err)
+	require.Equal(t, 3, server.Requests())
+
+	// keeps failing.
+	server.FailNext(http.StatusInternalServerError, http.StatusInternalServerError,
+		http.StatusInternalServerError)
+	_, err = service.GetOrderByExternalIDForUser(context.Background(), o.OrderID, 1)
+	require.True(t, errors.Is(err, ErrUnavailable))
+	require.Equal(t, 6, server.Requests())
+
+	// client errors are not retried.
+	server.FailNext(http.StatusBadRequest)
+	_, err = service.GetOrderByExternalIDForUser(context.Background(), o.OrderID, 1)
+	require.True(t, errors.Is(err, ErrInvalidOrder))
+	require.Equal(t, 7, server.Requests())
+}
+
+// TestHTTPService_RetriesTransportErrors checks that requests failing in
+// transport are retried like 5xx responses.
+func TestHTTPService_RetriesTransportErrors(t *testing.T) {
+	server := ordertest.NewServer()
+	defer server.Close()
+	service := newTestService(server.URL)
+
+	o := ordertest.Order{OrderID: uuid.New(), UserID: 1, Amount: "5.00", Currency: "EUR"}
+	server.AddOrder(o)
+
+	server.FailNext(ordertest.CloseConnection, ordertest.CloseConnection)
+	_, err := service.GetOrderByExternalIDForUser(context.Background(), o.OrderID, 1)
+	require.NoError(t, err)
+	require.Equal(t, 3, server.Requests())
+}
+
+// TestHTTPService_Unreachable checks that transport errors are reported as
+// the order service being unavailable once the retries are exhausted.
+func TestHTTPService_Unreachable(t *testing.T) {
+	server := ordertest.NewServer()
+	server.Close()
+
+	_, err := newTestService(server.URL).GetOrderByExternalIDForUser(
+		context.Background(), uuid.New(), 1)
+	require.True(t, errors.Is(err, ErrUnavailable))
+}
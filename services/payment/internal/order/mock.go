@@ -3,6 +3,8 @@ package order
 import (
 	"context"
 	"errors"
+	"fmt"
+	"payment-system/pkg/money"
 
 	"github.com/google/uuid"
 )
@@ -34,20 +36,19 @@ func (m *MockOrderService) GetOrderByExternalIDForUser(ctx context.Context,
 	externalOrderID uuid.UUID, userID uint32) (*Order, error) {
 	switch m.ResponseType {
 	case MockErrorNotFound:
-		return nil, errors.New("order not found")
+		return nil, ErrOrderNotFound
 	case MockErrorUserMismatch:
-		return nil, errors.New("order does not belong to the user")
+		return nil, ErrUserMismatch
 	case MockErrorInternal:
-		return nil, errors.New("internal error occurred")
+		return nil, ErrUnavailable
 	case MockErrorBadRequest:
-		return nil, errors.New("bad request: invalid external order id")
+		return nil, fmt.Errorf("%w: invalid external order id", ErrInvalidOrder)
 	case MockSuccess:
 		return &Order{
 			ExternalID:  externalOrderID,
 			UserID:      userID,
 			ServiceName: "Service A",
-			Amount:      99.99,
-			Currency:    "USD",
+			Amount:      money.MustParse("99.99", "USD"),
 			BankAccount: "123456789",
 			BankCode:    "XY",
 		}, nil
@@ -2,19 +2,33 @@ package order
 
 import (
 	"context"
+	"errors"
+	"payment-system/pkg/money"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrOrderNotFound is returned when the order does not exist.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrUserMismatch is returned when the order belongs to another user.
+	ErrUserMismatch = errors.New("order does not belong to the user")
+	// ErrInvalidOrder is returned when the order service rejects the order ID
+	// or returns an order that can't be paid.
+	ErrInvalidOrder = errors.New("invalid order")
+	// ErrUnavailable is returned when the order service can't be reached or
+	// keeps failing after the retries.
+	ErrUnavailable = errors.New("order service unavailable")
+)
+
 // Order represents a user's order with relevant payment and service details.
 type Order struct {
-	ExternalID  uuid.UUID // External order ID
-	ServiceName string    // Name of the service
-	Amount      float64   // Amount
-	Currency    string    // Currency code
-	BankAccount string    // Bank account of the service
-	BankCode    string    // Bank code
-	UserID      uint32    // User ID
+	ExternalID  uuid.UUID   // External order ID
+	ServiceName string      // Name of the service
+	Amount      money.Money // Amount and currency
+	BankAccount string      // Bank account of the service
+	BankCode    string      // Bank code
+	UserID      uint32      // User ID
 }
 
 // Service defines the interface for retrieving order details.
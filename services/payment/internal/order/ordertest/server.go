@@ -0,0 +1,109 @@
+// Package ordertest provides a fake Order Service for testing the order
+// HTTP client offline.
+package ordertest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Order is an order served by the fake Order Service.
+type Order struct {
+	OrderID     uuid.UUID `json:"order_id"`
+	UserID      uint32    `json:"user_id"`
+	ServiceName string    `json:"service_name"`
+	Amount      string    `json:"amount"`
+	Currency    string    `json:"currency"`
+	BankAccount string    `json:"bank_account"`
+	BankCode    string    `json:"bank_code"`
+}
+
+// CloseConnection can be passed to FailNext to close the connection of a
+// request without answering it.
+const CloseConnection = -1
+
+// Server is a fake Order Service serving GET /orders/{orderId}. Unknown
+// orders are answered with 404.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	orders   map[uuid.UUID]Order
+	failures []int
+	requests int
+}
+
+// NewServer starts a new fake Order Service. Call Close when done.
+func NewServer() *Server {
+	s := &Server{orders: make(map[uuid.UUID]Order)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/{orderId}", s.getOrder)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// AddOrder adds an order to the fake service.
+func (s *Server) AddOrder(o Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[o.OrderID] = o
+}
+
+// FailNext answers the next requests with the given statuses, in order,
+// before serving orders again. CloseConnection drops the request instead.
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Requests returns the number of requests received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mu.Unlock()
+		if status == CloseConnection {
+			closeConnection(w)
+			return
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	orderID, err := uuid.Parse(r.PathValue("orderId"))
+	o, ok := s.orders[orderID]
+	s.mu.Unlock()
+
+	switch {
+	case err != nil:
+		http.Error(w, "invalid order id", http.StatusBadRequest)
+	case !ok:
+		http.Error(w, "order not found", http.StatusNotFound)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(o)
+	}
+}
+
+// closeConnection closes the connection of the request without writing a
+// response.
+func closeConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = conn.Close()
+}
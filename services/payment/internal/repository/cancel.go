@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"payment-system/pkg/events"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+)
+
+// CancelPayment cancels a payment that was not charged yet. The payment row
+// is locked while the transition is validated, so a concurrent processor
+// result either completes the payment first, making the cancellation fail
+// with domain.ErrInvalidTransition, or finds it cancelled. Within the same
+// transaction the payments.cancelled event, for the wallet to release any
+// hold, and the payments.finalized event are enqueued.
+func (r *PaymentRepository) CancelPayment(ctx context.Context, paymentID uuid.UUID,
+	reason string) (*domain.Payment, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := transitionStatus(ctx, tx, paymentID, domain.StatusChange{
+		To:          domain.StatusCancelled,
+		Reason:      reason,
+		SourceEvent: "cancel/" + paymentID.String(),
+	})
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	now := time.Now()
+	cancelled := &events.PaymentCancelledV1{
+		PaymentID:   payment.PaymentID,
+		UserID:      payment.UserID,
+		Amount:      payment.Amount.AmountString(),
+		Currency:    string(payment.Amount.Currency()),
+		Reason:      reason,
+		CancelledAt: now,
+	}
+	if err := r.enqueueEvent(ctx, tx, payment.PaymentID, cancelled); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	if err := r.enqueueFinalized(ctx, tx, payment, now); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return payment, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/db"
+	"payment-system/pkg/events"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+)
+
+// ErrEventAlreadyProcessed is returned when a consumed event was already
+// applied.
+var ErrEventAlreadyProcessed = errors.New("event already processed")
+
+// ConsumedEvent identifies an event consumed from Kafka by its CloudEvents
+// source and id, which are unique together.
+type ConsumedEvent struct {
+	Source string
+	ID     string
+	Type   string
+}
+
+// ApplyEventStatus applies the status change caused by a consumed event
+// exactly once. Within one transaction it records the event as processed,
+// moves the payment through the status state machine and, when the payment
+// reaches an outcome, enqueues the payments.finalized event. It returns
+// ErrEventAlreadyProcessed for redelivered events, ErrPaymentNotFound and
+// domain.ErrInvalidTransition, leaving the database unchanged.
+func (r *PaymentRepository) ApplyEventStatus(ctx context.Context, event ConsumedEvent,
+	paymentID uuid.UUID, change domain.StatusChange) (*domain.Payment, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	insert := `
+		INSERT INTO payment.processed_events
+		(source, event_id, event_type, processed_at)
+		VALUES ($1,$2,$3,$4)
+		ON CONFLICT (source, event_id) DO NOTHING
+	`
+	inserted, err := tx.Exec(ctx, insert, event.Source, event.ID, event.Type, now)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	if inserted == 0 {
+		_ = tx.Rollback(ctx)
+		return nil, ErrEventAlreadyProcessed
+	}
+
+	payment, err := transitionStatus(ctx, tx, paymentID, change)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := r.enqueueFinalized(ctx, tx, payment, now); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return payment, nil
+}
+
+// finalizedStatuses are the payment outcomes published as payments.finalized.
+var finalizedStatuses = []domain.PaymentStatus{
+	domain.StatusCompleted,
+	domain.StatusFailed,
+	domain.StatusCancelled,
+	domain.StatusExpired,
+}
+
+// enqueueFinalized enqueues the payments.finalized event within the
+// transaction when the payment reached an outcome.
+func (r *PaymentRepository) enqueueFinalized(ctx context.Context, tx db.Tx,
+	payment *domain.Payment, at time.Time) error {
+	if !slices.Contains(finalizedStatuses, payment.Status) {
+		return nil
+	}
+
+	finalized := &events.PaymentFinalizedV1{
+		PaymentID:   payment.PaymentID,
+		UserID:      payment.UserID,
+		Status:      string(payment.Status),
+		Amount:      payment.Amount.AmountString(),
+		Currency:    string(payment.Amount.Currency()),
+		FinalizedAt: at,
+	}
+	if payment.FailureReason != nil {
+		finalized.FailureReason = *payment.FailureReason
+	}
+	return r.enqueueEvent(ctx, tx, payment.PaymentID, finalized)
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/db"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+)
+
+// expiryLockKey is the advisory lock key held while expiring payments, so a
+// single replica sweeps at a time.
+const expiryLockKey int64 = 0x7061796d_00000001
+
+// ErrExpiryLocked is returned when another replica is expiring payments.
+var ErrExpiryLocked = errors.New("payment expiry is running on another replica")
+
+// ExpiryRule moves payments that stayed in a status longer than TTL to
+// another status.
+type ExpiryRule struct {
+	Status domain.PaymentStatus
+	TTL    time.Duration
+	To     domain.PaymentStatus
+	Reason string
+}
+
+// ExpirePayments applies the given rules to at most limit payments per rule,
+// oldest first, and returns the expired payments. Each payment goes through
+// the status state machine and gets its payments.finalized event enqueued,
+// which releases any wallet hold. It runs in a single transaction holding an
+// advisory lock and returns ErrExpiryLocked if another replica holds it.
+func (r *PaymentRepository) ExpirePayments(ctx context.Context, rules []ExpiryRule,
+	limit int) ([]domain.Payment, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expired, err := r.expirePayments(ctx, tx, rules, limit)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return expired, nil
+}
+
+func (r *PaymentRepository) expirePayments(ctx context.Context, tx db.Tx,
+	rules []ExpiryRule, limit int) ([]domain.Payment, error) {
+	acquired, err := db.TryAdvisoryXactLock(ctx, tx, expiryLockKey)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrExpiryLocked
+	}
+
+	var expired []domain.Payment
+	now := time.Now()
+	for _, rule := range rules {
+		// payments being updated by a consumer are skipped until next sweep.
+		query := `
+			SELECT payment_id
+			FROM payment.payments
+			WHERE status = $1 AND updated_at < $2
+			ORDER BY updated_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		`
+		var paymentIDs []uuid.UUID
+		if err := tx.Select(ctx, &paymentIDs, query,
+			rule.Status, now.Add(-rule.TTL), limit); err != nil {
+			return nil, err
+		}
+
+		for _, paymentID := range paymentIDs {
+			payment, err := transitionStatus(ctx, tx, paymentID, domain.StatusChange{
+				To:          rule.To,
+				Reason:      rule.Reason,
+				SourceEvent: "expiry",
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := r.enqueueFinalized(ctx, tx, payment, now); err != nil {
+				return nil, err
+			}
+			expired = append(expired, *payment)
+		}
+	}
+	return expired, nil
+}
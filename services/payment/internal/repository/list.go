@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+)
+
+// Cursor is the position of a payment in the listing order, used for keyset
+// pagination.
+type Cursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        int64     `json:"id"`
+}
+
+// PaymentFilter selects the payments returned by ListPayments. Zero values
+// do not filter.
+type PaymentFilter struct {
+	UserID          *uint32
+	Status          domain.PaymentStatus
+	Currency        string
+	ExternalOrderID *uuid.UUID
+	// CreatedFrom and CreatedTo bound created_at, inclusive and exclusive.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// After returns the payments following the given cursor.
+	After *Cursor
+	Limit int
+}
+
+// ListPayments returns the payments matching the filter, newest first,
+// ordered by (created_at, id) so pages are stable while payments are created.
+func (r *PaymentRepository) ListPayments(ctx context.Context,
+	filter PaymentFilter) ([]domain.Payment, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	where := func(condition string, values ...any) {
+		for _, v := range values {
+			args = append(args, v)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if filter.UserID != nil {
+		where("user_id = ?", *filter.UserID)
+	}
+	if filter.Status != "" {
+		where("status = ?", filter.Status)
+	}
+	if filter.Currency != "" {
+		where("currency = ?", filter.Currency)
+	}
+	if filter.ExternalOrderID != nil {
+		where("external_order_id = ?", *filter.ExternalOrderID)
+	}
+	if filter.CreatedFrom != nil {
+		where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		where("created_at < ?", *filter.CreatedTo)
+	}
+	if filter.After != nil {
+		where("(created_at, id) < (?, ?)", filter.After.CreatedAt, filter.After.ID)
+	}
+
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payment.payments
+	`
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	var rows []paymentRow
+	if err := r.db.Select(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	payments := make([]domain.Payment, 0, len(rows))
+	for i := range rows {
+		payment, err := rows[i].toPayment()
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *payment)
+	}
+	return payments, nil
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-system/pkg/db"
+	"payment-system/pkg/events"
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+)
+
+var (
+	// ErrRefundNotFound is returned when the requested refund does not exist.
+	ErrRefundNotFound = errors.New("refund not found")
+	// ErrPaymentNotRefundable is returned when refunding a payment that is
+	// not completed.
+	ErrPaymentNotRefundable = errors.New("payment is not refundable")
+	// ErrRefundExceedsAmount is returned when a refund would make the refunds
+	// of a payment exceed its amount.
+	ErrRefundExceedsAmount = errors.New("refund exceeds refundable amount")
+)
+
+// refundColumns are the columns selected to read a domain.Refund.
+const refundColumns = `id, refund_id, payment_id, user_id, idempotency_key,
+	request_hash, amount, currency, reason, status, created_at, updated_at`
+
+// refundRow is a row of payment.refunds, converted to a domain.Refund by
+// toRefund.
+type refundRow struct {
+	domain.Refund
+	RawAmount   money.Amount `db:"amount"`
+	RawCurrency string       `db:"currency"`
+}
+
+// toRefund combines the amount and currency columns into the refund amount.
+func (r *refundRow) toRefund() (*domain.Refund, error) {
+	amount, err := money.New(r.RawAmount, r.RawCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("refund %s: %w", r.RefundID, err)
+	}
+	refund := r.Refund
+	refund.Amount = amount
+	return &refund, nil
+}
+
+// CreateRefund requests a refund of a completed payment. A refund without
+// amount refunds the remaining refundable amount. Within one transaction it
+// locks the payment, checks that the refunds never exceed the payment
+// amount, inserts the refund, moves the payment to PARTIALLY_REFUNDED or
+// REFUNDED and enqueues the refund.requested event. The refund ID, amount,
+// status and timestamps are set on success.
+func (r *PaymentRepository) CreateRefund(ctx context.Context,
+	refund *domain.Refund) (*domain.Payment, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := r.createRefund(ctx, tx, refund)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (r *PaymentRepository) createRefund(ctx context.Context, tx db.Tx,
+	refund *domain.Refund) (*domain.Payment, error) {
+	lock := `
+		SELECT ` + paymentColumns + `
+		FROM payment.payments
+		WHERE payment_id = $1
+		FOR UPDATE
+	`
+	payment, err := getPayment(ctx, tx, lock, refund.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !payment.Status.IsRefundable() {
+		return nil, fmt.Errorf("%w: payment is %s", ErrPaymentNotRefundable, payment.Status)
+	}
+
+	// compute the amount left to refund.
+	var refunded money.Amount
+	sum := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM payment.refunds
+		WHERE payment_id = $1
+	`
+	if err := tx.QueryRow(ctx, &refunded, sum, refund.PaymentID); err != nil {
+		return nil, err
+	}
+	refundedAmount, err := money.New(refunded, string(payment.Amount.Currency()))
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := payment.Amount.Sub(refundedAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	if refund.Amount.IsZero() {
+		refund.Amount = remaining
+	}
+	left, err := remaining.Sub(refund.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if left.IsNegative() || !refund.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: %s refundable", ErrRefundExceedsAmount, remaining)
+	}
+
+	now := time.Now()
+	refund.RefundID = uuid.New()
+	refund.Status = domain.RefundStatusRequested
+	insert := `
+		INSERT INTO payment.refunds
+		(refund_id, payment_id, user_id, idempotency_key, request_hash,
+			amount, currency, reason, status, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$10)
+		RETURNING id
+	`
+	if err := tx.QueryRow(ctx, &refund.ID, insert,
+		refund.RefundID,
+		refund.PaymentID,
+		refund.UserID,
+		refund.IdempotencyKey,
+		refund.RequestHash,
+		refund.Amount,
+		refund.Amount.Currency(),
+		refund.Reason,
+		refund.Status,
+		now,
+	); err != nil {
+		if db.IsUniqueViolation(err) {
+			return nil, ErrDuplicateIdempotencyKey
+		}
+		return nil, err
+	}
+	refund.CreatedAt = now
+	refund.UpdatedAt = now
+
+	status := domain.StatusPartiallyRefunded
+	if left.IsZero() {
+		status = domain.StatusRefunded
+	}
+	payment, err = transitionStatus(ctx, tx, refund.PaymentID, domain.StatusChange{
+		To:          status,
+		Reason:      "refund of " + refund.Amount.String(),
+		SourceEvent: "refund/" + refund.RefundID.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	requested := &events.RefundRequestedV1{
+		RefundID:    refund.RefundID,
+		PaymentID:   refund.PaymentID,
+		UserID:      refund.UserID,
+		Amount:      refund.Amount.AmountString(),
+		Currency:    string(refund.Amount.Currency()),
+		RequestedAt: now,
+	}
+	if refund.Reason != nil {
+		requested.Reason = *refund.Reason
+	}
+	if err := r.enqueueEvent(ctx, tx, refund.PaymentID, requested); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// GetRefundByIdempotencyKey returns the refund of the payment created with
+// the given idempotency key, or ErrRefundNotFound if there is none.
+func (r *PaymentRepository) GetRefundByIdempotencyKey(ctx context.Context,
+	paymentID, idempotencyKey uuid.UUID) (*domain.Refund, error) {
+	query := `
+		SELECT ` + refundColumns + `
+		FROM payment.refunds
+		WHERE payment_id = $1 AND idempotency_key = $2
+	`
+
+	var row refundRow
+	if err := r.db.QueryRow(ctx, &row, query, paymentID, idempotencyKey); err != nil {
+		if db.IsNoRows(err) {
+			return nil, ErrRefundNotFound
+		}
+		return nil, err
+	}
+	return row.toRefund()
+}
@@ -2,30 +2,60 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"payment-system/pkg/db"
+	pkgdomain "payment-system/pkg/domain"
+	"payment-system/pkg/events"
+	"payment-system/pkg/money"
+	"payment-system/pkg/outbox"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/config"
 	"github.com/walker-16/payment-system/services/payment/internal/domain"
 )
 
+var (
+	// ErrPaymentNotFound is returned when the requested payment does not exist.
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrDuplicateIdempotencyKey is returned when the user already created a
+	// payment with the same idempotency key.
+	ErrDuplicateIdempotencyKey = errors.New("duplicate idempotency key")
+)
+
 type PaymentRepo interface {
 	InsertPayment(ctx context.Context, p *domain.Payment) error
+	GetByIdempotencyKey(ctx context.Context, userID uint32,
+		idempotencyKey uuid.UUID) (*domain.Payment, error)
+	GetPayment(ctx context.Context, paymentID uuid.UUID) (*domain.Payment, error)
+	ListPayments(ctx context.Context, filter PaymentFilter) ([]domain.Payment, error)
+	UpdateStatus(ctx context.Context, paymentID uuid.UUID,
+		change domain.StatusChange) (*domain.Payment, error)
+	CreateRefund(ctx context.Context, refund *domain.Refund) (*domain.Payment, error)
+	GetRefundByIdempotencyKey(ctx context.Context,
+		paymentID, idempotencyKey uuid.UUID) (*domain.Refund, error)
+	CancelPayment(ctx context.Context, paymentID uuid.UUID, reason string) (*domain.Payment, error)
 }
 
+// paymentColumns are the columns selected to read a domain.Payment.
+const paymentColumns = `id, payment_id, external_order_id, user_id, idempotency_key,
+	request_hash, amount, currency, status, failure_reason, created_at, updated_at`
+
 type PaymentRepository struct {
 	db db.DB
+	// contentType is the encoding of the events written to the outbox.
+	contentType string
 }
 
-func NewPaymentRepository(db db.DB) *PaymentRepository {
-	return &PaymentRepository{db: db}
+func NewPaymentRepository(db db.DB, contentType string) *PaymentRepository {
+	return &PaymentRepository{db: db, contentType: contentType}
 }
 
 func (r *PaymentRepository) InsertPayment(ctx context.Context, p *domain.Payment) error {
 	// start transaction
 	tx, err := r.db.BeginTx(ctx)
 	if err != nil {
-		_ = tx.Rollback(ctx)
 		return err
 	}
 
@@ -33,49 +63,50 @@ func (r *PaymentRepository) InsertPayment(ctx context.Context, p *domain.Payment
 	now := time.Now()
 	paymentInsert := `
 		INSERT INTO payment.payments
-		(payment_id, external_order_id, user_id, idempotency_key, amount, currency, status, created_at, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		(payment_id, external_order_id, user_id, idempotency_key, request_hash,
+			amount, currency, status, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
 		RETURNING id
 	`
 
-	if _, err := tx.Exec(ctx, paymentInsert,
+	if err := tx.QueryRow(ctx, &p.ID, paymentInsert,
 		p.PaymentID,
 		p.ExternalOrderID,
 		p.UserID,
 		p.IdempotencyKey,
+		p.RequestHash,
 		p.Amount,
-		p.Currency,
+		p.Amount.Currency(),
 		p.Status,
 		now,
 		now,
 	); err != nil {
 		_ = tx.Rollback(ctx)
+		if db.IsUniqueViolation(err) {
+			return ErrDuplicateIdempotencyKey
+		}
 		return err
 	}
 
-	// marshal payment as event payload
-	payload, err := json.Marshal(p)
-	if err != nil {
+	// record initial status
+	if err := insertStatusHistory(ctx, tx, p.PaymentID, nil, domain.StatusChange{
+		To:     p.Status,
+		Reason: "payment created",
+	}, now); err != nil {
 		_ = tx.Rollback(ctx)
 		return err
 	}
 
-	// insert outbox event
-	outboxInsert := `
-		INSERT INTO payment.outbox
-		(aggregate_id, aggregate_type, event_type, payload, status, created_at, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7)
-	`
-
-	if _, err := tx.Exec(ctx, outboxInsert,
-		p.PaymentID,
-		"payment",
-		"payment_created",
-		payload,
-		"PENDING",
-		now,
-		now,
-	); err != nil {
+	// insert payment requested event
+	requested := &events.PaymentRequestedV1{
+		PaymentID:       p.PaymentID,
+		ExternalOrderID: p.ExternalOrderID,
+		UserID:          p.UserID,
+		Amount:          p.Amount.AmountString(),
+		Currency:        string(p.Amount.Currency()),
+		RequestedAt:     now,
+	}
+	if err := r.enqueueEvent(ctx, tx, p.PaymentID, requested); err != nil {
 		_ = tx.Rollback(ctx)
 		return err
 	}
@@ -84,7 +115,92 @@ func (r *PaymentRepository) InsertPayment(ctx context.Context, p *domain.Payment
 		_ = tx.Rollback(ctx)
 		return err
 	}
+
+	p.CreatedAt = now
+	p.UpdatedAt = now
 	return nil
 }
 
+// GetByIdempotencyKey returns the payment created by the given user with the
+// given idempotency key, or ErrPaymentNotFound if there is none.
+func (r *PaymentRepository) GetByIdempotencyKey(ctx context.Context,
+	userID uint32, idempotencyKey uuid.UUID) (*domain.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payment.payments
+		WHERE user_id = $1 AND idempotency_key = $2
+	`
+
+	return getPayment(ctx, r.db, query, userID, idempotencyKey)
+}
+
+// enqueueEvent encodes the given event with the configured content type and
+// inserts it in the payment outbox within the transaction.
+func (r *PaymentRepository) enqueueEvent(ctx context.Context, tx db.Tx,
+	paymentID uuid.UUID, event events.Event) error {
+	payload, err := events.Encode(event, r.contentType)
+	if err != nil {
+		return err
+	}
+
+	return outbox.Enqueue(ctx, tx, &pkgdomain.Outbox{
+		AggregateID:   paymentID,
+		AggregateType: domain.AggregatePayment,
+		EventType:     event.EventType(),
+		Payload:       payload,
+		ContentType:   r.contentType,
+	}, outbox.WithSchema(config.Schema))
+}
+
+// GetPayment returns the payment with the given ID, or ErrPaymentNotFound if
+// it does not exist.
+func (r *PaymentRepository) GetPayment(ctx context.Context,
+	paymentID uuid.UUID) (*domain.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payment.payments
+		WHERE payment_id = $1
+	`
+
+	return getPayment(ctx, r.db, query, paymentID)
+}
+
+// rowQuerier is implemented by db.DB and db.Tx.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// paymentRow is a row of payment.payments, converted to a domain.Payment by
+// toPayment.
+type paymentRow struct {
+	domain.Payment
+	RawAmount   money.Amount `db:"amount"`
+	RawCurrency string       `db:"currency"`
+}
+
+// toPayment combines the amount and currency columns into the payment amount.
+func (r *paymentRow) toPayment() (*domain.Payment, error) {
+	amount, err := money.New(r.RawAmount, r.RawCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("payment %s: %w", r.PaymentID, err)
+	}
+	payment := r.Payment
+	payment.Amount = amount
+	return &payment, nil
+}
+
+// getPayment reads the single payment selected by the query, or returns
+// ErrPaymentNotFound.
+func getPayment(ctx context.Context, q rowQuerier, query string,
+	args ...any) (*domain.Payment, error) {
+	var row paymentRow
+	if err := q.QueryRow(ctx, &row, query, args...); err != nil {
+		if db.IsNoRows(err) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+	return row.toPayment()
+}
+
 // TODO: pending add test to repository InsertPayment.
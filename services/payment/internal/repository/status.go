@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/db"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/payment/internal/domain"
+)
+
+// UpdateStatus moves the payment to the status of the given change and
+// records the transition in the status history. It returns the updated
+// payment, ErrPaymentNotFound, or domain.ErrInvalidTransition when the
+// transition table does not allow the change.
+func (r *PaymentRepository) UpdateStatus(ctx context.Context, paymentID uuid.UUID,
+	change domain.StatusChange) (*domain.Payment, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := transitionStatus(ctx, tx, paymentID, change)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return payment, nil
+}
+
+// transitionStatus is the only path changing the status of an existing
+// payment. It locks the payment row, validates the transition, updates the
+// payment and records the transition, all within the given transaction.
+func transitionStatus(ctx context.Context, tx db.Tx, paymentID uuid.UUID,
+	change domain.StatusChange) (*domain.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payment.payments
+		WHERE payment_id = $1
+		FOR UPDATE
+	`
+
+	payment, err := getPayment(ctx, tx, query, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := payment.Status.ValidateTransition(change.To); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	from := payment.Status
+	payment.Status = change.To
+	payment.UpdatedAt = now
+	if (change.To == domain.StatusFailed || change.To == domain.StatusExpired) &&
+		change.Reason != "" {
+		payment.FailureReason = &change.Reason
+	}
+
+	update := `
+		UPDATE payment.payments
+		SET status = $1, failure_reason = $2, updated_at = $3
+		WHERE payment_id = $4
+	`
+	if _, err := tx.Exec(ctx, update,
+		payment.Status,
+		payment.FailureReason,
+		now,
+		paymentID,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := insertStatusHistory(ctx, tx, paymentID, &from, change, now); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// insertStatusHistory records a status transition of the payment. from is
+// nil for the initial status.
+func insertStatusHistory(ctx context.Context, tx db.Tx, paymentID uuid.UUID,
+	from *domain.PaymentStatus, change domain.StatusChange, at time.Time) error {
+	if change.To == "" {
+		return errors.New("status change without target status")
+	}
+
+	insert := `
+		INSERT INTO payment.payment_status_history
+		(payment_id, from_status, to_status, reason, source_event, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6)
+	`
+	_, err := tx.Exec(ctx, insert,
+		paymentID,
+		from,
+		change.To,
+		nullString(change.Reason),
+		nullString(change.SourceEvent),
+		at,
+	)
+	return err
+}
+
+// nullString returns nil for empty strings so they are stored as NULL.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,14 @@
+-- idempotency keys are scoped to the user that sent them, so the same key used
+-- by two users creates two payments.
+ALTER TABLE payment.payments
+DROP CONSTRAINT IF EXISTS payments_idempotency_key_key;
+
+DROP INDEX IF EXISTS payment.idx_payments_idempotency_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_user_idempotency_key
+ON payment.payments (user_id, idempotency_key);
+
+-- fingerprint of the request that created the payment, used to detect an
+-- idempotency key reused with a different request.
+ALTER TABLE payment.payments
+ADD COLUMN IF NOT EXISTS request_hash VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- reason reported when a payment fails, returned by the payment status endpoint.
+ALTER TABLE payment.payments
+ADD COLUMN IF NOT EXISTS failure_reason TEXT;
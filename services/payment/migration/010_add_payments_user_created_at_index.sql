@@ -0,0 +1,3 @@
+-- keyset pagination of the payments of a user, newest first.
+CREATE INDEX IF NOT EXISTS idx_payments_user_created_at_id
+ON payment.payments (user_id, created_at DESC, id DESC);
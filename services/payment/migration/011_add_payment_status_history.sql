@@ -0,0 +1,13 @@
+-- every payment status transition, including the initial PENDING status.
+CREATE TABLE IF NOT EXISTS payment.payment_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    payment_id UUID NOT NULL REFERENCES payment.payments (payment_id),
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    source_event VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_status_history_payment_id
+ON payment.payment_status_history (payment_id, created_at);
@@ -0,0 +1,12 @@
+-- events consumed from Kafka, recorded in the same transaction as their
+-- effects so redelivered events are applied only once.
+CREATE TABLE IF NOT EXISTS payment.processed_events (
+    source VARCHAR(255) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (source, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at
+ON payment.processed_events (processed_at);
@@ -0,0 +1,20 @@
+-- full and partial refunds of completed payments. The sum of the refunds of
+-- a payment never exceeds its amount, enforced while holding the payment row
+-- lock.
+CREATE TABLE IF NOT EXISTS payment.refunds (
+    id BIGSERIAL PRIMARY KEY,
+    refund_id UUID NOT NULL UNIQUE,
+    payment_id UUID NOT NULL REFERENCES payment.payments (payment_id),
+    user_id BIGINT NOT NULL,
+    idempotency_key UUID NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    amount NUMERIC(18,2) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    reason TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'REQUESTED',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refunds_payment_idempotency_key
+ON payment.refunds (payment_id, idempotency_key);
@@ -0,0 +1,3 @@
+-- lookup of the payments stuck in a status by the expiry sweeper.
+CREATE INDEX IF NOT EXISTS idx_payments_status_updated_at
+ON payment.payments (status, updated_at);
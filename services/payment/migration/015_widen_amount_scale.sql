@@ -0,0 +1,7 @@
+-- amounts keep the minor units of 3-decimal currencies such as KWD, which
+-- NUMERIC(18,2) silently rounded. Same precision as the wallet ledger.
+ALTER TABLE payment.payments
+ALTER COLUMN amount TYPE NUMERIC(19,4);
+
+ALTER TABLE payment.refunds
+ALTER COLUMN amount TYPE NUMERIC(19,4);
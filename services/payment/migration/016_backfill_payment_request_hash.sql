@@ -0,0 +1,8 @@
+-- payments created before request fingerprints were stored get the
+-- fingerprint of the request that created them, the SHA-256 of
+-- {"external_order_id":"..."}, so retries of those requests are replayed.
+UPDATE payment.payments
+SET request_hash = encode(
+    sha256(convert_to('{"external_order_id":"' || external_order_id::text || '"}', 'UTF8')),
+    'hex')
+WHERE request_hash = '';
@@ -1,5 +1,233 @@
 package main
 
+import (
+	"context"
+	"log/slog"
+	"os/signal"
+	"payment-system/pkg/config"
+	"payment-system/pkg/db"
+	"payment-system/pkg/events"
+	"payment-system/pkg/kafka"
+	"payment-system/pkg/logger"
+	"payment-system/pkg/outbox"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	walletCfg "github.com/walker-16/payment-system/services/wallet/internal/config"
+	"github.com/walker-16/payment-system/services/wallet/internal/consumer"
+	"github.com/walker-16/payment-system/services/wallet/internal/expiry"
+	"github.com/walker-16/payment-system/services/wallet/internal/handler"
+	"github.com/walker-16/payment-system/services/wallet/internal/reconcile"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+// defaultShutdownTimeout
+const defaultShutdownTimeout = 10 * time.Second
+
 func main() {
-	// TODO implement processor service.
+	// set up context that is cancelled on SIGN/SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// initialize logger.
+	logger := logger.NewSlogLogger(logger.LoggerConfig{
+		Format:    logger.FormatJSON,
+		Level:     slog.LevelDebug,
+		AddSource: false,
+	})
+
+	// load configuration.
+	cfg, err := config.Load[walletCfg.WalletConfiguration](ctx)
+	if err != nil {
+		logger.Fatal("failed to load configuration", "error", err)
+	}
+	if len(cfg.Limits.TopUpDaily) == 0 {
+		logger.Warn("TOPUP_DAILY_LIMIT is empty, top-ups are disabled")
+	}
+	if len(cfg.Limits.WithdrawalDaily) == 0 {
+		logger.Warn("WITHDRAWAL_DAILY_LIMIT is empty, withdrawals are disabled")
+	}
+	contentType, err := events.ContentTypeFor(cfg.Kafka.EventEncoding)
+	if err != nil {
+		logger.Fatal("invalid event encoding", "error", err)
+	}
+
+	// initialize db client.
+	dbConfig := db.Config{
+		DSN:             cfg.DB.DNS,
+		MaxConns:        cfg.DB.MaxConns,
+		MinConns:        cfg.DB.MinConns,
+		MaxConnIdleTime: cfg.DB.MaxConnIdleTime,
+		MaxConnLifetime: cfg.DB.MaxConnLifetime,
+		AppName:         walletCfg.AppName,
+	}
+	db, err := db.New(ctx, dbConfig)
+	if err != nil {
+		logger.Fatal("failed to create db client", "error", err)
+	}
+	defer db.Close()
+
+	// create or update the outbox tables of the wallet schema.
+	if err := outbox.Migrate(ctx, db, outbox.WithSchema(walletCfg.Schema)); err != nil {
+		logger.Fatal("failed to migrate outbox", "error", err)
+	}
+
+	// initialize kafka producer.
+	producer, err := kafka.NewProducer(cfg.Kafka.Brokers, walletCfg.AppName, logger)
+	if err != nil {
+		logger.Fatal("failed to create kafka producer", "error", err)
+	}
+	defer producer.Close()
+
+	// background workers are stopped after the server on shutdown.
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+
+	// initialize relayer for process pending outbox and send event to kafka.
+	relayerConfig := outbox.RelayerConfig{
+		BatchSize:   cfg.Outbox.BatchSize,
+		Interval:    cfg.Outbox.PollInterval,
+		MaxInFlight: cfg.Outbox.MaxInFlight,
+		Workers:     cfg.Outbox.Workers,
+		Retry: outbox.RetryPolicy{
+			MaxAttempts: cfg.Outbox.MaxAttempts,
+			BaseBackoff: cfg.Outbox.BaseBackoff,
+			MaxBackoff:  cfg.Outbox.MaxBackoff,
+		},
+		Topic:  events.Topic,
+		Source: walletCfg.EventSource,
+	}
+	outboxRelayer := outbox.NewRelayer(db, producer, logger, relayerConfig,
+		outbox.WithSchema(walletCfg.Schema))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		outboxRelayer.Start(workersCtx)
+	}()
+
+	// initialize janitor for archive published and purge dead outbox events.
+	outboxJanitor := outbox.NewJanitor(db, logger, outbox.JanitorConfig{
+		Interval:      cfg.Outbox.Janitor.Interval,
+		Retention:     cfg.Outbox.Janitor.Retention,
+		DeadRetention: cfg.Outbox.Janitor.DeadRetention,
+		BatchSize:     cfg.Outbox.Janitor.BatchSize,
+		DryRun:        cfg.Outbox.Janitor.DryRun,
+	}, outbox.WithSchema(walletCfg.Schema))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		outboxJanitor.Start(workersCtx)
+	}()
+
+	// initialize consumer for reserve, capture and release payment funds.
+	walletRepository := repository.NewWalletRepository(db, contentType)
+	eventHandler := consumer.NewWalletEventHandler(walletRepository, logger)
+	eventConsumer, err := kafka.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.ConsumerGroup,
+		consumer.Topics, events.NewConsumerHandler(
+			func(meta *kafka.Event, e events.Event) error {
+				return eventHandler.Handle(workersCtx, meta, e)
+			}), logger)
+	if err != nil {
+		logger.Fatal("failed to create kafka consumer", "error", err)
+	}
+	defer eventConsumer.Close()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := eventConsumer.Start(workersCtx); err != nil && workersCtx.Err() == nil {
+			logger.Error("wallet event consumer stopped", "error", err)
+		}
+	}()
+
+	// initialize reconciler for check the account balances against the journal.
+	reconciler := reconcile.NewReconciler(walletRepository, logger, reconcile.Config{
+		Interval: cfg.Reconcile.Interval,
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reconciler.Start(workersCtx)
+	}()
+
+	// initialize sweeper for release the holds kept longer than their TTL.
+	holdSweeper := expiry.NewSweeper(walletRepository, logger, expiry.Config{
+		Interval:  cfg.Hold.ExpiryInterval,
+		BatchSize: cfg.Hold.BatchSize,
+		TTL:       cfg.Hold.TTL,
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		holdSweeper.Start(workersCtx)
+	}()
+
+	// create and run server.
+	app := newServer(walletRepository, cfg, logger)
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("wallet server started", "port", cfg.Port)
+		serverErr <- app.Listen(":" + cfg.Port)
+	}()
+
+	// wait for shutdown signal or server error.
+	select {
+	case <-ctx.Done():
+		logger.Info("shutdown signal received")
+	case err := <-serverErr:
+		if err != nil {
+			logger.Error("wallet server stopped unexpectedly", "error", err)
+		}
+	}
+
+	// graceful shutdown.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		logger.Error("failed to shutdown wallet server gracefully", "error", err)
+	}
+
+	// stop the outbox relayer, janitor, event consumer, reconciler and hold
+	// sweeper and wait for the in-flight batches to finish.
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		logger.Error("timed out waiting for background workers to stop")
+	}
+
+	logger.Info("wallet server exited succesfully")
+}
+
+func newServer(repository repository.WalletRepo,
+	cfg *walletCfg.WalletConfiguration, logger logger.Logger) *fiber.App {
+	// create a new Fiber app.
+	app := fiber.New()
+	app.Use(recover.New())
+
+	// Register routes.
+	registerRoutes(app, repository, cfg, logger)
+	return app
+}
+
+func registerRoutes(app *fiber.App, repository repository.WalletRepo,
+	cfg *walletCfg.WalletConfiguration, logger logger.Logger) {
+	v1 := app.Group("/v1")
+	h := handler.NewWalletHandler(repository, logger,
+		handler.WithDailyLimits(cfg.Limits.TopUpDaily, cfg.Limits.WithdrawalDaily))
+	v1.Post("/wallets", h.CreateWallet)
+	v1.Get("/wallets", h.ListWallets)
+	v1.Get("/wallets/:wallet_id", h.GetWallet)
+	v1.Post("/wallets/:wallet_id/topups", h.CreateTopUp)
+	v1.Post("/wallets/:wallet_id/withdrawals", h.CreateWithdrawal)
+	v1.Post("/wallets/:wallet_id/transfers", h.CreateTransfer)
 }
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"time"
+
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+)
+
+const AppName = "Wallet"
+
+// Schema is the database schema owned by the wallet service.
+const Schema = "wallet"
+
+// EventSource is the CloudEvents source of the events published by the
+// wallet service.
+const EventSource = "/payment-system/wallet"
+
+// WalletConfiguration holds the configuration for the wallet service.
+type WalletConfiguration struct {
+	LogLevel  string `env:"LOG_LEVEL,default=INFO"`
+	Port      string `env:"PORT,default=8001"`
+	DB        DBConfig
+	Kafka     KafkaConfig
+	Outbox    OutboxConfig
+	Reconcile ReconcileConfig
+	Hold      HoldConfig
+	Limits    LimitsConfig
+}
+
+// DBConfig holds database connection and pool settings.
+type DBConfig struct {
+	DNS             string        `env:"DB_DNS,required"`
+	MaxConns        int32         `env:"DB_MAX_CONNS,default=10"`
+	MinConns        int32         `env:"DB_MIN_CONNS,default=0"`
+	MaxConnIdleTime time.Duration `env:"DB_MAX_CONN_IDLE_TIME,default=30m"`
+	MaxConnLifetime time.Duration `env:"DB_MAX_CONN_LIFETIME,default=1h"`
+}
+
+// ReconcileConfig holds the settings of the check of the account balances
+// against the journal.
+type ReconcileConfig struct {
+	Interval time.Duration `env:"RECONCILE_INTERVAL,default=1h"`
+}
+
+// HoldConfig holds the hold expiry sweeper settings. A zero TTL disables the
+// expiry of holds.
+type HoldConfig struct {
+	TTL            time.Duration `env:"HOLD_TTL,default=2h"`
+	ExpiryInterval time.Duration `env:"HOLD_EXPIRY_INTERVAL,default=1m"`
+	BatchSize      int           `env:"HOLD_EXPIRY_BATCH_SIZE,default=100"`
+}
+
+// LimitsConfig holds the largest amount a user may top up and withdraw in
+// each currency in a UTC day, as comma-separated CURRENCY:AMOUNT pairs, e.g.
+// USD:1000,JPY:150000. Currencies without a limit can't be topped up or
+// withdrawn, so both variables are required; set one empty to disable the
+// operation.
+type LimitsConfig struct {
+	TopUpDaily      domain.DailyLimits `env:"TOPUP_DAILY_LIMIT,required"`
+	WithdrawalDaily domain.DailyLimits `env:"WITHDRAWAL_DAILY_LIMIT,required"`
+}
+
+// KafkaConfig holds Kafka connection and event encoding settings.
+type KafkaConfig struct {
+	Brokers []string `env:"KAFKA_BROKERS,required"`
+	// ConsumerGroup is the consumer group of the wallet event consumer.
+	ConsumerGroup string `env:"KAFKA_CONSUMER_GROUP,default=wallet"`
+	// EventEncoding selects the encoding of published events, json or protobuf.
+	EventEncoding string `env:"KAFKA_EVENT_ENCODING,default=json"`
+}
+
+// OutboxConfig holds the outbox relayer settings.
+type OutboxConfig struct {
+	BatchSize    int           `env:"OUTBOX_BATCH_SIZE,default=10"`
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL,default=1s"`
+	MaxInFlight  int           `env:"OUTBOX_MAX_IN_FLIGHT,default=10"`
+	Workers      int           `env:"OUTBOX_WORKERS,default=1"`
+	MaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS,default=10"`
+	BaseBackoff  time.Duration `env:"OUTBOX_BASE_BACKOFF,default=1s"`
+	MaxBackoff   time.Duration `env:"OUTBOX_MAX_BACKOFF,default=5m"`
+	Janitor      OutboxJanitorConfig
+}
+
+// OutboxJanitorConfig holds the outbox retention settings.
+type OutboxJanitorConfig struct {
+	Interval      time.Duration `env:"OUTBOX_JANITOR_INTERVAL,default=1h"`
+	Retention     time.Duration `env:"OUTBOX_RETENTION,default=168h"`
+	DeadRetention time.Duration `env:"OUTBOX_DEAD_RETENTION,default=720h"`
+	BatchSize     int           `env:"OUTBOX_JANITOR_BATCH_SIZE,default=500"`
+	DryRun        bool          `env:"OUTBOX_JANITOR_DRY_RUN,default=false"`
+}
@@ -0,0 +1,171 @@
+// Package consumer applies the events published by the payment and processor
+// services to wallets.
+package consumer
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/events"
+	"payment-system/pkg/kafka"
+	"payment-system/pkg/logger"
+	"payment-system/pkg/money"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+// Topics are the Kafka topics consumed by the wallet service.
+var Topics = []string{
+	events.TopicPaymentsRequested,
+	events.TopicPaymentCompleted,
+	events.TopicPaymentFailed,
+	events.TopicPaymentsCancelled,
+	events.TopicPaymentsFinalized,
+}
+
+// EventRepo applies consumed events to wallets.
+type EventRepo interface {
+	ReserveFunds(ctx context.Context, event repository.ConsumedEvent,
+		reservation repository.Reservation) (*domain.Hold, error)
+	CaptureHold(ctx context.Context, event repository.ConsumedEvent,
+		paymentID uuid.UUID) (*domain.Hold, error)
+	ReleaseHold(ctx context.Context, event repository.ConsumedEvent,
+		paymentID uuid.UUID, reason string) (*domain.Hold, error)
+}
+
+// WalletEventHandler reserves the funds of requested payments, captures them
+// when the payment completes and releases them when it does not.
+type WalletEventHandler struct {
+	repository EventRepo
+	logger     logger.Logger
+}
+
+// NewWalletEventHandler creates a new instance of WalletEventHandler.
+func NewWalletEventHandler(repository EventRepo, logger logger.Logger) *WalletEventHandler {
+	return &WalletEventHandler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+// Handle applies the given event. Redelivered events and events of holds
+// already settled are skipped and insufficient funds are an expected outcome,
+// so only transient failures are returned. The kafka consumer retries those
+// without committing the offset of the event.
+//
+// The outcome of a payment may arrive from the processor and again in
+// payments.finalized, the hold is settled by whichever arrives first.
+func (h *WalletEventHandler) Handle(ctx context.Context, meta *kafka.Event, e events.Event) error {
+	switch e := e.(type) {
+	case *events.PaymentRequestedV1:
+		return h.reserveFunds(ctx, meta, e)
+	case *events.PaymentCompletedV1:
+		return h.settleHold(ctx, meta, e.PaymentID, domain.HoldStatusCaptured, "")
+	case *events.PaymentFailedV1:
+		return h.settleHold(ctx, meta, e.PaymentID, domain.HoldStatusReleased, e.Reason)
+	case *events.PaymentCancelledV1:
+		return h.settleHold(ctx, meta, e.PaymentID, domain.HoldStatusReleased,
+			"payment cancelled")
+	case *events.PaymentFinalizedV1:
+		if e.Status == "COMPLETED" {
+			return h.settleHold(ctx, meta, e.PaymentID, domain.HoldStatusCaptured, "")
+		}
+		return h.settleHold(ctx, meta, e.PaymentID, domain.HoldStatusReleased,
+			"payment "+strings.ToLower(e.Status))
+	default:
+		h.logger.Debug("ignoring event", logger.String("type", meta.Type))
+		return nil
+	}
+}
+
+// reserveFunds holds the funds of a requested payment.
+func (h *WalletEventHandler) reserveFunds(ctx context.Context, meta *kafka.Event,
+	e *events.PaymentRequestedV1) error {
+	source := meta.Source + "/" + meta.ID
+	consumed := repository.ConsumedEvent{Source: meta.Source, ID: meta.ID, Type: meta.Type}
+
+	// an invalid amount is left zero, which ReserveFunds rejects with
+	// funds.insufficient so the payment fails instead of waiting forever.
+	amount, err := money.Parse(e.Amount, e.Currency)
+	if err != nil {
+		h.logger.Warn("invalid payment amount",
+			logger.String("payment_id", e.PaymentID.String()),
+			logger.String("event", source),
+			logger.Error(err))
+	}
+
+	hold, err := h.repository.ReserveFunds(ctx, consumed, repository.Reservation{
+		PaymentID: e.PaymentID,
+		UserID:    e.UserID,
+		Amount:    amount,
+	})
+	switch {
+	case err == nil:
+		h.logger.Info("funds reserved",
+			logger.String("payment_id", e.PaymentID.String()),
+			logger.String("hold_id", hold.HoldID.String()),
+			logger.String("event", source))
+		return nil
+	case errors.Is(err, repository.ErrInsufficientFunds):
+		h.logger.Info("insufficient funds for payment",
+			logger.String("payment_id", e.PaymentID.String()),
+			logger.String("event", source),
+			logger.Error(err))
+		return nil
+	case errors.Is(err, repository.ErrEventAlreadyProcessed),
+		errors.Is(err, repository.ErrHoldExists):
+		h.logger.Debug("skipping already processed event",
+			logger.String("payment_id", e.PaymentID.String()),
+			logger.String("event", source))
+		return nil
+	default:
+		return err
+	}
+}
+
+// settleHold captures or releases the hold of a payment.
+func (h *WalletEventHandler) settleHold(ctx context.Context, meta *kafka.Event,
+	paymentID uuid.UUID, to domain.HoldStatus, reason string) error {
+	source := meta.Source + "/" + meta.ID
+	consumed := repository.ConsumedEvent{Source: meta.Source, ID: meta.ID, Type: meta.Type}
+
+	var hold *domain.Hold
+	var err error
+	if to == domain.HoldStatusCaptured {
+		hold, err = h.repository.CaptureHold(ctx, consumed, paymentID)
+	} else {
+		hold, err = h.repository.ReleaseHold(ctx, consumed, paymentID, reason)
+	}
+	switch {
+	case err == nil:
+		h.logger.Info("hold settled",
+			logger.String("payment_id", paymentID.String()),
+			logger.String("hold_id", hold.HoldID.String()),
+			logger.String("status", string(hold.Status)),
+			logger.String("event", source))
+		return nil
+	case errors.Is(err, repository.ErrEventAlreadyProcessed):
+		h.logger.Debug("skipping already processed event",
+			logger.String("event", source))
+		return nil
+	case errors.Is(err, repository.ErrHoldReleased):
+		// the payment completed after its hold was released, e.g. by expiry,
+		// the funds must be recovered manually.
+		h.logger.Error("completed payment whose hold is no longer held",
+			logger.String("payment_id", paymentID.String()),
+			logger.String("event", source),
+			logger.Error(err))
+		return nil
+	case errors.Is(err, repository.ErrHoldNotFound),
+		errors.Is(err, repository.ErrHoldNotHeld):
+		h.logger.Debug("skipping event",
+			logger.String("payment_id", paymentID.String()),
+			logger.String("event", source),
+			logger.Error(err))
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,153 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-system/pkg/events"
+	"payment-system/pkg/kafka"
+	"payment-system/pkg/logger"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+type MockRepo struct {
+	Err          error
+	Reservations []repository.Reservation
+	Consumed     []repository.ConsumedEvent
+	Captured     []uuid.UUID
+	Released     map[uuid.UUID]string
+}
+
+func (m *MockRepo) ReserveFunds(ctx context.Context, event repository.ConsumedEvent,
+	reservation repository.Reservation) (*domain.Hold, error) {
+	m.Consumed = append(m.Consumed, event)
+	m.Reservations = append(m.Reservations, reservation)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return &domain.Hold{HoldID: uuid.New(), PaymentID: reservation.PaymentID,
+		Amount: reservation.Amount, Status: domain.HoldStatusHeld}, nil
+}
+
+func (m *MockRepo) CaptureHold(ctx context.Context, event repository.ConsumedEvent,
+	paymentID uuid.UUID) (*domain.Hold, error) {
+	m.Consumed = append(m.Consumed, event)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	m.Captured = append(m.Captured, paymentID)
+	return &domain.Hold{HoldID: uuid.New(), PaymentID: paymentID,
+		Status: domain.HoldStatusCaptured}, nil
+}
+
+func (m *MockRepo) ReleaseHold(ctx context.Context, event repository.ConsumedEvent,
+	paymentID uuid.UUID, reason string) (*domain.Hold, error) {
+	m.Consumed = append(m.Consumed, event)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.Released == nil {
+		m.Released = make(map[uuid.UUID]string)
+	}
+	m.Released[paymentID] = reason
+	return &domain.Hold{HoldID: uuid.New(), PaymentID: paymentID,
+		Status: domain.HoldStatusReleased}, nil
+}
+
+func requested(amount string) (*kafka.Event, *events.PaymentRequestedV1) {
+	e := &events.PaymentRequestedV1{PaymentID: uuid.New(), ExternalOrderID: uuid.New(),
+		UserID: 4, Amount: amount, Currency: "USD", RequestedAt: time.Now()}
+	meta := &kafka.Event{ID: "42", Source: "/payment-system/payment", Type: e.EventType()}
+	return meta, e
+}
+
+// TestHandle_ReserveFunds checks that requested payments are reserved and
+// that expected outcomes are not retried.
+func TestHandle_ReserveFunds(t *testing.T) {
+	repo := &MockRepo{}
+	h := NewWalletEventHandler(repo, logger.NewNoopLogger())
+
+	meta, e := requested("12.50")
+	require.NoError(t, h.Handle(context.Background(), meta, e))
+	require.Len(t, repo.Reservations, 1)
+	require.Equal(t, e.PaymentID, repo.Reservations[0].PaymentID)
+	require.Equal(t, uint32(4), repo.Reservations[0].UserID)
+	require.Equal(t, "12.50 USD", repo.Reservations[0].Amount.String())
+	require.Equal(t, repository.ConsumedEvent{Source: meta.Source, ID: "42",
+		Type: events.TypePaymentRequestedV1}, repo.Consumed[0])
+
+	for _, err := range []error{
+		fmt.Errorf("%w: insufficient available balance", repository.ErrInsufficientFunds),
+		repository.ErrEventAlreadyProcessed,
+		repository.ErrHoldExists,
+	} {
+		repo.Err = err
+		require.NoError(t, h.Handle(context.Background(), meta, e))
+	}
+
+	repo.Err = errors.New("connection refused")
+	require.Error(t, h.Handle(context.Background(), meta, e))
+}
+
+// TestHandle_InvalidAmount checks that a payment with an invalid amount is
+// still handed to the repository, which rejects it.
+func TestHandle_InvalidAmount(t *testing.T) {
+	repo := &MockRepo{}
+	h := NewWalletEventHandler(repo, logger.NewNoopLogger())
+
+	meta, e := requested("abc")
+	require.NoError(t, h.Handle(context.Background(), meta, e))
+	require.Len(t, repo.Reservations, 1)
+	require.True(t, repo.Reservations[0].Amount.IsZero())
+
+	// other events are ignored.
+	require.NoError(t, h.Handle(context.Background(), &kafka.Event{Type: "funds.reserved.v1"},
+		&events.FundsReservedV1{}))
+	require.Len(t, repo.Reservations, 1)
+}
+
+// TestHandle_SettleHold checks that holds are captured when payments complete
+// and released when they fail or are cancelled, and that settled holds are
+// skipped.
+func TestHandle_SettleHold(t *testing.T) {
+	repo := &MockRepo{}
+	h := NewWalletEventHandler(repo, logger.NewNoopLogger())
+	ctx := context.Background()
+	meta := &kafka.Event{ID: "1", Source: "/test"}
+
+	completed, failed, cancelled, expired := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	require.NoError(t, h.Handle(ctx, meta, &events.PaymentCompletedV1{PaymentID: completed}))
+	require.NoError(t, h.Handle(ctx, meta, &events.PaymentFailedV1{PaymentID: failed,
+		Reason: "declined"}))
+	require.NoError(t, h.Handle(ctx, meta, &events.PaymentCancelledV1{PaymentID: cancelled}))
+	require.NoError(t, h.Handle(ctx, meta, &events.PaymentFinalizedV1{PaymentID: expired,
+		Status: "EXPIRED"}))
+	require.NoError(t, h.Handle(ctx, meta, &events.PaymentFinalizedV1{PaymentID: completed,
+		Status: "COMPLETED"}))
+
+	require.Equal(t, []uuid.UUID{completed, completed}, repo.Captured)
+	require.Equal(t, map[uuid.UUID]string{
+		failed:    "declined",
+		cancelled: "payment cancelled",
+		expired:   "payment expired",
+	}, repo.Released)
+
+	for _, err := range []error{
+		repository.ErrEventAlreadyProcessed,
+		repository.ErrHoldNotFound,
+		repository.ErrHoldNotHeld,
+		repository.ErrHoldReleased,
+	} {
+		repo.Err = err
+		require.NoError(t, h.Handle(ctx, meta, &events.PaymentCompletedV1{PaymentID: completed}))
+	}
+
+	repo.Err = errors.New("connection refused")
+	require.Error(t, h.Handle(ctx, meta, &events.PaymentFailedV1{PaymentID: failed}))
+}
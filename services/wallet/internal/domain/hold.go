@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AggregateWallet is the aggregate type of the events published by the
+// wallet service.
+const AggregateWallet = "wallet"
+
+// Journal entry kinds of the hold lifecycle.
+const (
+	// EntryHold moves the funds of a payment from the available to the held
+	// account of a wallet.
+	EntryHold EntryKind = "HOLD"
+	// EntryCapture moves the held funds of a completed payment to the
+	// settlement account.
+	EntryCapture EntryKind = "CAPTURE"
+	// EntryRelease moves the held funds of a payment that did not complete
+	// back to the available account.
+	EntryRelease EntryKind = "RELEASE"
+)
+
+// HoldStatus is the status of a hold.
+type HoldStatus string
+
+const (
+	// HoldStatusHeld is the status of the funds reserved for a payment in
+	// progress.
+	HoldStatusHeld HoldStatus = "HELD"
+	// HoldStatusCaptured is the status of the funds of a completed payment.
+	HoldStatusCaptured HoldStatus = "CAPTURED"
+	// HoldStatusReleased is the status of the funds returned to the wallet.
+	HoldStatusReleased HoldStatus = "RELEASED"
+)
+
+// Hold represents the funds of a wallet reserved for a payment.
+type Hold struct {
+	ID        int64       `db:"id"`
+	HoldID    uuid.UUID   `db:"hold_id"`
+	WalletID  uuid.UUID   `db:"wallet_id"`
+	PaymentID uuid.UUID   `db:"payment_id"`
+	Amount    money.Money `db:"-"` // amount and currency columns
+	Status    HoldStatus  `db:"status"`
+	Reason    *string     `db:"reason"`
+	CreatedAt time.Time   `db:"created_at"`
+	UpdatedAt time.Time   `db:"updated_at"`
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnbalancedEntry is returned when the postings of a journal entry do not
+// sum to zero.
+var ErrUnbalancedEntry = errors.New("journal entry is not balanced")
+
+// AccountType is the type of a ledger account.
+type AccountType string
+
+const (
+	// AccountAvailable holds the funds a wallet can spend.
+	AccountAvailable AccountType = "AVAILABLE"
+	// AccountHeld holds the funds of a wallet reserved for pending payments.
+	AccountHeld AccountType = "HELD"
+	// AccountSettlement is the system account receiving the funds of the
+	// completed payments, owed to the merchants.
+	AccountSettlement AccountType = "SETTLEMENT"
+	// AccountExternal is the system account of the funds entering or leaving
+	// the system through top-ups and withdrawals.
+	AccountExternal AccountType = "EXTERNAL"
+)
+
+// EntryKind is the business operation recorded by a journal entry.
+type EntryKind string
+
+// Posting moves an amount in or out of an account. A positive amount
+// increases the balance of the account and a negative one decreases it.
+type Posting struct {
+	AccountID uuid.UUID
+	Amount    money.Money
+}
+
+// Entry is a journal entry, recorded once and never modified.
+type Entry struct {
+	EntryID uuid.UUID
+	Kind    EntryKind
+	// Reference identifies what caused the entry, e.g. a payment ID.
+	Reference   string
+	Description string
+	Postings    []Posting
+	CreatedAt   time.Time
+}
+
+// Transfer returns the postings moving the amount from one account to
+// another.
+func Transfer(from, to uuid.UUID, amount money.Money) []Posting {
+	return []Posting{
+		{AccountID: from, Amount: amount.Neg()},
+		{AccountID: to, Amount: amount},
+	}
+}
+
+// Validate checks that the entry has at least two non-zero postings and that
+// they sum to zero in every currency.
+func (e *Entry) Validate() error {
+	if e.Kind == "" {
+		return errors.New("journal entry kind is required")
+	}
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("%w: at least two postings are required", ErrUnbalancedEntry)
+	}
+
+	sums := make(map[money.Currency]money.Money)
+	for _, p := range e.Postings {
+		if p.Amount.IsZero() {
+			return fmt.Errorf("%w: posting to account %s has a zero amount",
+				ErrUnbalancedEntry, p.AccountID)
+		}
+		sum, ok := sums[p.Amount.Currency()]
+		if !ok {
+			sum = money.Zero(p.Amount.Currency())
+		}
+		sum, err := sum.Add(p.Amount)
+		if err != nil {
+			return err
+		}
+		sums[p.Amount.Currency()] = sum
+	}
+	for currency, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("%w: postings in %s sum to %s",
+				ErrUnbalancedEntry, currency, sum.AmountString())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"errors"
+	"payment-system/pkg/money"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+)
+
+// TestEntryValidate checks that only entries whose postings sum to zero in
+// every currency are valid.
+func TestEntryValidate(t *testing.T) {
+	from, to := uuid.New(), uuid.New()
+
+	entry := &Entry{Kind: "TEST", Postings: Transfer(from, to, money.MustParse("10.50", "USD"))}
+	require.NoError(t, entry.Validate())
+
+	// balanced in each currency.
+	entry.Postings = append(entry.Postings,
+		Transfer(to, from, money.MustParse("3", "EUR"))...)
+	require.NoError(t, entry.Validate())
+
+	tests := map[string][]Posting{
+		"single posting": {{AccountID: from, Amount: money.MustParse("1", "USD")}},
+		"unbalanced": {
+			{AccountID: from, Amount: money.MustParse("-10", "USD")},
+			{AccountID: to, Amount: money.MustParse("9.99", "USD")},
+		},
+		"currencies mixed": {
+			{AccountID: from, Amount: money.MustParse("-10", "USD")},
+			{AccountID: to, Amount: money.MustParse("10", "EUR")},
+		},
+		"zero amount": {
+			{AccountID: from, Amount: money.MustParse("0", "USD")},
+			{AccountID: to, Amount: money.MustParse("0", "USD")},
+		},
+	}
+	for name, postings := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := (&Entry{Kind: "TEST", Postings: postings}).Validate()
+			require.True(t, errors.Is(err, ErrUnbalancedEntry), err)
+		})
+	}
+
+	require.Error(t, (&Entry{Postings: Transfer(from, to, money.MustParse("1", "USD"))}).Validate())
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"fmt"
+	"payment-system/pkg/money"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OperationType is the type of an operation requested by a user on a wallet.
+type OperationType string
+
+const (
+	// OperationTopUp adds funds from outside the system to a wallet.
+	OperationTopUp OperationType = "TOPUP"
+	// OperationWithdrawal takes funds from a wallet out of the system.
+	OperationWithdrawal OperationType = "WITHDRAWAL"
+)
+
+// Journal entry kinds of the operations.
+const (
+	EntryTopUp      EntryKind = "TOPUP"
+	EntryWithdrawal EntryKind = "WITHDRAWAL"
+)
+
+// IsCredit reports whether the operation adds funds to the wallet.
+func (t OperationType) IsCredit() bool {
+	return t == OperationTopUp
+}
+
+// Operation represents a top-up or a withdrawal of a wallet.
+type Operation struct {
+	ID             int64         `db:"id"`
+	OperationID    uuid.UUID     `db:"operation_id"`
+	WalletID       uuid.UUID     `db:"wallet_id"`
+	UserID         uint32        `db:"user_id"`
+	Type           OperationType `db:"type"`
+	IdempotencyKey uuid.UUID     `db:"idempotency_key"`
+	RequestHash    string        `db:"request_hash"`
+	Amount         money.Money   `db:"-"` // amount and currency columns
+	// BalanceAfter is the available balance of the wallet after the operation.
+	BalanceAfter money.Money `db:"-"` // balance_after and currency columns
+	EntryID      uuid.UUID   `db:"entry_id"`
+	CreatedAt    time.Time   `db:"created_at"`
+}
+
+// DailyLimits holds the largest amount a user may top up or withdraw in each
+// currency in a UTC day. Operations in currencies without a limit are not
+// allowed.
+type DailyLimits map[money.Currency]money.Money
+
+// ParseDailyLimits parses limits written as comma-separated CURRENCY:AMOUNT
+// pairs, e.g. USD:1000,JPY:150000. An empty string allows no currency.
+func ParseDailyLimits(s string) (DailyLimits, error) {
+	limits := make(DailyLimits)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		code, amount, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("daily limit %q: want CURRENCY:AMOUNT", pair)
+		}
+		limit, err := money.Parse(strings.TrimSpace(amount), code)
+		if err != nil {
+			return nil, fmt.Errorf("daily limit %q: %w", pair, err)
+		}
+		if !limit.IsPositive() {
+			return nil, fmt.Errorf("daily limit %q: amount must be positive", pair)
+		}
+		if _, ok := limits[limit.Currency()]; ok {
+			return nil, fmt.Errorf("daily limit %q: duplicated currency", pair)
+		}
+		limits[limit.Currency()] = limit
+	}
+	return limits, nil
+}
+
+// EnvDecode parses the limits from an environment variable.
+func (l *DailyLimits) EnvDecode(val string) error {
+	limits, err := ParseDailyLimits(val)
+	if err != nil {
+		return err
+	}
+	*l = limits
+	return nil
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"payment-system/pkg/money"
+	"testing"
+
+	"github.com/test-go/testify/require"
+)
+
+// TestParseDailyLimits checks that limits are read per currency and that
+// malformed, non-positive, too precise and duplicated limits are rejected.
+func TestParseDailyLimits(t *testing.T) {
+	limits, err := ParseDailyLimits("usd:1000, JPY:150000,KWD:300.123")
+	require.NoError(t, err)
+	require.Equal(t, DailyLimits{
+		"USD": money.MustParse("1000", "USD"),
+		"JPY": money.MustParse("150000", "JPY"),
+		"KWD": money.MustParse("300.123", "KWD"),
+	}, limits)
+
+	limits, err = ParseDailyLimits("")
+	require.NoError(t, err)
+	require.Empty(t, limits)
+
+	for _, invalid := range []string{"1000", "USD:ten", "XXX:10", "USD:0", "JPY:10.5", "USD:1,USD:2"} {
+		_, err := ParseDailyLimits(invalid)
+		require.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EntryTransfer moves funds from the available account of a wallet to the
+// available account of another one.
+const EntryTransfer EntryKind = "TRANSFER"
+
+// WalletTransfer represents funds sent by a user from one of their wallets
+// to the wallet of another user in the same currency.
+type WalletTransfer struct {
+	ID           int64     `db:"id"`
+	TransferID   uuid.UUID `db:"transfer_id"`
+	FromWalletID uuid.UUID `db:"from_wallet_id"`
+	ToWalletID   uuid.UUID `db:"to_wallet_id"`
+	// UserID is the user sending the funds, the owner of FromWalletID.
+	UserID         uint32      `db:"user_id"`
+	ToUserID       uint32      `db:"to_user_id"`
+	IdempotencyKey uuid.UUID   `db:"idempotency_key"`
+	RequestHash    string      `db:"request_hash"`
+	Amount         money.Money `db:"-"` // amount and currency columns
+	// BalanceAfter is the available balance of the sending wallet after the
+	// transfer.
+	BalanceAfter money.Money `db:"-"` // balance_after and currency columns
+	EntryID      uuid.UUID   `db:"entry_id"`
+	CreatedAt    time.Time   `db:"created_at"`
+}
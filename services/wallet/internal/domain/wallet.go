@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Wallet represents the wallet of a user in a currency, with its balances
+// read from the ledger accounts of the wallet.
+type Wallet struct {
+	ID       int64          `db:"id"`
+	WalletID uuid.UUID      `db:"wallet_id"`
+	UserID   uint32         `db:"user_id"`
+	Currency money.Currency `db:"currency"`
+	// Available can be spent, while Held is reserved for pending payments.
+	Available money.Money `db:"-"` // available column
+	Held      money.Money `db:"-"` // held column
+	// AvailableAccountID and HeldAccountID are the ledger accounts of the
+	// wallet balances.
+	AvailableAccountID uuid.UUID `db:"available_account_id"`
+	HeldAccountID      uuid.UUID `db:"held_account_id"`
+	CreatedAt          time.Time `db:"created_at"`
+	UpdatedAt          time.Time `db:"updated_at"`
+}
+
+// Total returns the available and held funds of the wallet.
+func (w *Wallet) Total() money.Money {
+	total, _ := w.Available.Add(w.Held)
+	return total
+}
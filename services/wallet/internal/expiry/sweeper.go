@@ -0,0 +1,91 @@
+// Package expiry releases holds kept longer than their TTL, for instance
+// after the outcome of a payment was lost.
+package expiry
+
+import (
+	"context"
+	"payment-system/pkg/logger"
+	"time"
+
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+)
+
+// Repo releases expired holds.
+type Repo interface {
+	ExpireHolds(ctx context.Context, before time.Time, limit int) ([]domain.Hold, error)
+}
+
+// Config holds the sweeper settings. A zero TTL disables the expiry of holds.
+type Config struct {
+	Interval  time.Duration
+	BatchSize int
+	TTL       time.Duration
+}
+
+// Sweeper periodically releases the holds older than the TTL back to the
+// available balance of their wallets. Replicas skip the holds locked by each
+// other, so they can all run it.
+type Sweeper struct {
+	repository Repo
+	logger     logger.Logger
+	cfg        Config
+}
+
+// NewSweeper creates a new instance of Sweeper.
+func NewSweeper(repository Repo, logger logger.Logger, cfg Config) *Sweeper {
+	cfg.BatchSize = max(cfg.BatchSize, 1)
+	return &Sweeper{
+		repository: repository,
+		logger:     logger,
+		cfg:        cfg,
+	}
+}
+
+// Start runs the sweeper until the context is cancelled.
+func (s *Sweeper) Start(ctx context.Context) {
+	s.logger.Info("starting hold expiry sweeper")
+
+	for {
+		if _, err := s.Run(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error("failed to expire holds", logger.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("hold expiry sweeper stopped due to context cancellation")
+			return
+		case <-time.After(s.cfg.Interval):
+		}
+	}
+}
+
+// Run releases the expired holds in batches and returns how many were
+// released.
+func (s *Sweeper) Run(ctx context.Context) (int, error) {
+	if s.cfg.TTL <= 0 {
+		return 0, nil
+	}
+
+	total := 0
+	before := time.Now().Add(-s.cfg.TTL)
+	for ctx.Err() == nil {
+		expired, err := s.repository.ExpireHolds(ctx, before, s.cfg.BatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		for _, h := range expired {
+			s.logger.Warn("hold expired",
+				logger.String("hold_id", h.HoldID.String()),
+				logger.String("payment_id", h.PaymentID.String()),
+				logger.String("amount", h.Amount.String()))
+		}
+		total += len(expired)
+
+		// a full batch may be followed by more expired holds.
+		if len(expired) < s.cfg.BatchSize {
+			break
+		}
+	}
+	return total, nil
+}
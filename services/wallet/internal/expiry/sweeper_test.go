@@ -0,0 +1,68 @@
+package expiry
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/logger"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+)
+
+// MockRepo returns the configured batches of expired holds in order.
+type MockRepo struct {
+	Batches [][]domain.Hold
+	Err     error
+	Before  time.Time
+	Calls   int
+}
+
+func (m *MockRepo) ExpireHolds(ctx context.Context, before time.Time,
+	limit int) ([]domain.Hold, error) {
+	m.Before = before
+	m.Calls++
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if len(m.Batches) == 0 {
+		return nil, nil
+	}
+	batch := m.Batches[0]
+	m.Batches = m.Batches[1:]
+	return batch, nil
+}
+
+func holds(n int) []domain.Hold {
+	batch := make([]domain.Hold, n)
+	for i := range batch {
+		batch[i] = domain.Hold{HoldID: uuid.New(), PaymentID: uuid.New(),
+			Status: domain.HoldStatusReleased}
+	}
+	return batch
+}
+
+// TestRun checks that full batches are followed by another sweep, that holds
+// older than the TTL are selected and that errors are returned.
+func TestRun(t *testing.T) {
+	repo := &MockRepo{Batches: [][]domain.Hold{holds(2), holds(1)}}
+	s := NewSweeper(repo, logger.NewNoopLogger(), Config{BatchSize: 2, TTL: time.Hour})
+
+	n, err := s.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, 2, repo.Calls)
+	require.WithinDuration(t, time.Now().Add(-time.Hour), repo.Before, time.Minute)
+
+	repo.Err = errors.New("connection refused")
+	_, err = s.Run(context.Background())
+	require.Error(t, err)
+
+	// without TTL nothing is swept.
+	repo.Calls = 0
+	_, err = NewSweeper(repo, logger.NewNoopLogger(), Config{}).Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, repo.Calls)
+}
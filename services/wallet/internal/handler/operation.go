@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"errors"
+	"payment-system/pkg/logger"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+// OperationRequest represents the payload for topping up or withdrawing
+// from a wallet. The amount is a decimal string in the wallet currency.
+type OperationRequest struct {
+	Amount string `json:"amount"`
+}
+
+// OperationResponse represents a top-up or a withdrawal and the available
+// balance of the wallet right after it.
+type OperationResponse struct {
+	OperationID uuid.UUID `json:"operation_id"`
+	WalletID    uuid.UUID `json:"wallet_id"`
+	Type        string    `json:"type"`
+	Amount      string    `json:"amount"`
+	Currency    string    `json:"currency"`
+	Balance     string    `json:"balance"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// operationFingerprint is the part of an operation request that identifies
+// it for idempotency.
+type operationFingerprint struct {
+	WalletID uuid.UUID            `json:"wallet_id"`
+	Type     domain.OperationType `json:"type"`
+	Amount   string               `json:"amount"`
+}
+
+func newOperationResponse(op *domain.Operation) *OperationResponse {
+	return &OperationResponse{
+		OperationID: op.OperationID,
+		WalletID:    op.WalletID,
+		Type:        string(op.Type),
+		Amount:      op.Amount.AmountString(),
+		Currency:    string(op.Amount.Currency()),
+		Balance:     op.BalanceAfter.AmountString(),
+		CreatedAt:   op.CreatedAt,
+	}
+}
+
+// CreateTopUp handles POST /v1/wallets/:wallet_id/topups requests.
+// It adds the amount to the available balance of the wallet.
+// Headers required:
+//   - idempotency-key: a unique key to ensure idempotent requests.
+//   - x-user-id: the ID of the user making the request.
+//
+// Top-ups in currencies without a daily limit or over the daily limit of the
+// user are rejected with StatusUnprocessableEntity.
+func (h *WalletHandler) CreateTopUp(c *fiber.Ctx) error {
+	return h.createOperation(c, domain.OperationTopUp, h.topUpDailyLimits)
+}
+
+// CreateWithdrawal handles POST /v1/wallets/:wallet_id/withdrawals requests.
+// It takes the amount from the available balance of the wallet.
+// Headers required:
+//   - idempotency-key: a unique key to ensure idempotent requests.
+//   - x-user-id: the ID of the user making the request.
+//
+// Withdrawals in currencies without a daily limit, over the available
+// balance or over the daily limit of the user are rejected with
+// StatusUnprocessableEntity.
+func (h *WalletHandler) CreateWithdrawal(c *fiber.Ctx) error {
+	return h.createOperation(c, domain.OperationWithdrawal, h.withdrawalDailyLimits)
+}
+
+// createOperation applies an operation of the given type to the wallet of
+// the request. Idempotency keys are scoped to the user. A request replayed
+// with the same key and body returns the original response, while reusing a
+// key with a different body is rejected with StatusUnprocessableEntity.
+func (h *WalletHandler) createOperation(c *fiber.Ctx, operationType domain.OperationType,
+	dailyLimits domain.DailyLimits) error {
+	ctx := c.UserContext()
+
+	idempotencyKey, err := idempotencyKeyFromHeader(c)
+	if err != nil {
+		return err
+	}
+	userID, err := userIDFromHeader(c)
+	if err != nil {
+		return err
+	}
+
+	var request OperationRequest
+	if err := c.BodyParser(&request); err != nil {
+		h.logger.Error("failed to parse operation request", logger.Error(err))
+		return fiber.NewError(fiber.StatusBadRequest,
+			"invalid JSON body")
+	}
+
+	wallet, err := h.ownWallet(c, userID)
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(request.Amount, wallet.Currency)
+	if err != nil {
+		return err
+	}
+
+	// replay the original response if the key was already used.
+	requestHash, err := fingerprint(&operationFingerprint{
+		WalletID: wallet.WalletID,
+		Type:     operationType,
+		Amount:   amount.AmountString(),
+	})
+	if err != nil {
+		h.logger.Error("failed to fingerprint operation request", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create operation")
+	}
+	existing, err := h.repository.GetOperationByIdempotencyKey(ctx, userID, idempotencyKey)
+	switch {
+	case err == nil:
+		return h.replayOperation(c, existing, requestHash)
+	case !errors.Is(err, repository.ErrOperationNotFound):
+		h.logger.Error("failed to get operation by idempotency-key", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create operation")
+	}
+
+	// the limit is checked after the replay, so operations already applied
+	// are replayed even if the limit of their currency was removed since.
+	dailyLimit, ok := dailyLimits[wallet.Currency]
+	if !ok {
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"operation not available in "+string(wallet.Currency))
+	}
+
+	op := &domain.Operation{
+		OperationID:    uuid.New(),
+		WalletID:       wallet.WalletID,
+		UserID:         userID,
+		Type:           operationType,
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		Amount:         amount,
+	}
+	err = h.repository.CreateOperation(ctx, op, dailyLimit)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrDuplicateIdempotencyKey):
+		// a concurrent request with the same key created the operation first.
+		existing, err := h.repository.GetOperationByIdempotencyKey(ctx, userID, idempotencyKey)
+		if err != nil {
+			h.logger.Error("failed to get operation by idempotency-key", logger.Error(err))
+			return fiber.NewError(fiber.StatusConflict,
+				"a request with the same idempotency-key is in progress")
+		}
+		return h.replayOperation(c, existing, requestHash)
+	case errors.Is(err, repository.ErrInsufficientFunds):
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"insufficient funds")
+	case errors.Is(err, repository.ErrDailyLimitExceeded):
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"daily limit exceeded")
+	case errors.Is(err, repository.ErrWalletNotFound):
+		return fiber.NewError(fiber.StatusNotFound,
+			"wallet not found")
+	default:
+		h.logger.Error("failed to create operation", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create operation")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(newOperationResponse(op))
+}
+
+// replayOperation responds to a request whose idempotency key already
+// created the given operation, provided the request body is the same.
+func (h *WalletHandler) replayOperation(c *fiber.Ctx, op *domain.Operation,
+	requestHash string) error {
+	if op.RequestHash != requestHash {
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"idempotency-key already used with a different request")
+	}
+	return c.Status(fiber.StatusCreated).JSON(newOperationResponse(op))
+}
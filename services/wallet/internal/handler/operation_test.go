@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"payment-system/pkg/money"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+// testLimits allows top-ups and withdrawals in USD only.
+var testLimits = WithDailyLimits(
+	domain.DailyLimits{"USD": money.MustParse("1000", "USD")},
+	domain.DailyLimits{"USD": money.MustParse("500", "USD")},
+)
+
+// newOperationRequest builds a top-up or withdrawal request for the wallet.
+func newOperationRequest(path string, walletID uuid.UUID, key uuid.UUID,
+	userID string, amount string) *http.Request {
+	body, _ := json.Marshal(OperationRequest{Amount: amount})
+	req := httptest.NewRequest(http.MethodPost, "/wallets/"+walletID.String()+path,
+		bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("idempotency-key", key.String())
+	req.Header.Set("x-user-id", userID)
+	return req
+}
+
+// TestCreateOperation checks the responses of top-ups and withdrawals to
+// valid requests and to the errors reported by the repository.
+func TestCreateOperation(t *testing.T) {
+	wallet := newWallet(7, "50.00", "0.00")
+
+	tests := []struct {
+		name       string
+		path       string
+		userID     string
+		amount     string
+		createErr  error
+		wantStatus int
+		wantType   domain.OperationType
+		wantLimit  string
+	}{
+		{"top-up", "/topups", "7", "10", nil, fiber.StatusCreated, domain.OperationTopUp, "1000"},
+		{"withdrawal", "/withdrawals", "7", "10.5", nil, fiber.StatusCreated,
+			domain.OperationWithdrawal, "500"},
+		{"insufficient funds", "/withdrawals", "7", "60", repository.ErrInsufficientFunds,
+			fiber.StatusUnprocessableEntity, "", ""},
+		{"daily limit", "/topups", "7", "10", repository.ErrDailyLimitExceeded,
+			fiber.StatusUnprocessableEntity, "", ""},
+		{"not positive", "/topups", "7", "0", nil, fiber.StatusBadRequest, "", ""},
+		{"invalid amount", "/topups", "7", "ten", nil, fiber.StatusBadRequest, "", ""},
+		{"too precise", "/topups", "7", "10.005", nil, fiber.StatusBadRequest, "", ""},
+		{"other user", "/topups", "8", "10", nil, fiber.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *domain.Operation
+			var limit money.Money
+			repo := &MockRepo{
+				GetFunc: func(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
+					return wallet, nil
+				},
+				CreateOperationFunc: func(ctx context.Context, op *domain.Operation,
+					dailyLimit money.Money) error {
+					if tt.createErr != nil {
+						return tt.createErr
+					}
+					op.BalanceAfter = money.MustParse("42.00", "USD")
+					created, limit = op, dailyLimit
+					return nil
+				},
+			}
+			app := newTestApp(repo, testLimits)
+
+			resp, err := app.Test(newOperationRequest(tt.path, wallet.WalletID, uuid.New(),
+				tt.userID, tt.amount))
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != fiber.StatusCreated {
+				return
+			}
+
+			var body OperationResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			require.Equal(t, created.OperationID, body.OperationID)
+			require.Equal(t, wallet.WalletID, body.WalletID)
+			require.Equal(t, string(tt.wantType), body.Type)
+			require.Equal(t, money.MustParse(tt.amount, "USD").AmountString(), body.Amount)
+			require.Equal(t, "USD", body.Currency)
+			require.Equal(t, "42.00", body.Balance)
+			require.Equal(t, money.MustParse(tt.wantLimit, "USD"), limit)
+		})
+	}
+}
+
+// TestCreateOperation_Replay checks that a request replayed with the same
+// idempotency key returns the original operation, even once its currency has
+// no limit, while reusing the key with a different request is rejected.
+func TestCreateOperation_Replay(t *testing.T) {
+	wallet := newWallet(7, "50.00", "0.00")
+	key := uuid.New()
+
+	var stored *domain.Operation
+	creates := 0
+	repo := &MockRepo{
+		GetFunc: func(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
+			return wallet, nil
+		},
+		GetOperationFunc: func(ctx context.Context, userID uint32,
+			k uuid.UUID) (*domain.Operation, error) {
+			if stored == nil || k != key || userID != stored.UserID {
+				return nil, repository.ErrOperationNotFound
+			}
+			return stored, nil
+		},
+		CreateOperationFunc: func(ctx context.Context, op *domain.Operation,
+			dailyLimit money.Money) error {
+			creates++
+			op.BalanceAfter = money.MustParse("60.00", "USD")
+			stored = op
+			return nil
+		},
+	}
+	app := newTestApp(repo, testLimits)
+
+	resp, err := app.Test(newOperationRequest("/topups", wallet.WalletID, key, "7", "10"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var first OperationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&first))
+
+	// the same amount written differently is the same request.
+	resp, err = app.Test(newOperationRequest("/topups", wallet.WalletID, key, "7", "10.00"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var replayed OperationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&replayed))
+	require.Equal(t, first.OperationID, replayed.OperationID)
+	require.Equal(t, 1, creates)
+
+	// operations are replayed after the limit of their currency is removed.
+	resp, err = newTestApp(repo).Test(newOperationRequest("/topups", wallet.WalletID, key, "7", "10"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&replayed))
+	require.Equal(t, first.OperationID, replayed.OperationID)
+	require.Equal(t, 1, creates)
+
+	resp, err = app.Test(newOperationRequest("/withdrawals", wallet.WalletID, key, "7", "10"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	require.Equal(t, 1, creates)
+}
+
+// TestCreateOperation_ConcurrentDuplicate checks that a request losing the
+// race for its idempotency key replays the operation of the winner.
+func TestCreateOperation_ConcurrentDuplicate(t *testing.T) {
+	wallet := newWallet(7, "50.00", "0.00")
+	key := uuid.New()
+
+	var winner *domain.Operation
+	repo := &MockRepo{
+		GetFunc: func(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
+			return wallet, nil
+		},
+		GetOperationFunc: func(ctx context.Context, userID uint32,
+			k uuid.UUID) (*domain.Operation, error) {
+			if winner == nil {
+				return nil, repository.ErrOperationNotFound
+			}
+			return winner, nil
+		},
+		CreateOperationFunc: func(ctx context.Context, op *domain.Operation,
+			dailyLimit money.Money) error {
+			w := *op
+			w.OperationID = uuid.New()
+			w.BalanceAfter = money.MustParse("40.00", "USD")
+			winner = &w
+			return repository.ErrDuplicateIdempotencyKey
+		},
+	}
+	app := newTestApp(repo, testLimits)
+
+	resp, err := app.Test(newOperationRequest("/withdrawals", wallet.WalletID, key, "7", "10"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var body OperationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, winner.OperationID, body.OperationID)
+	require.Equal(t, "40.00", body.Balance)
+}
+
+// TestCreateOperation_MissingHeaders checks that the idempotency-key and
+// x-user-id headers are required.
+func TestCreateOperation_MissingHeaders(t *testing.T) {
+	app := newTestApp(&MockRepo{}, testLimits)
+	walletID := uuid.New()
+
+	req := newOperationRequest("/topups", walletID, uuid.New(), "7", "10")
+	req.Header.Del("idempotency-key")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	req = newOperationRequest("/topups", walletID, uuid.New(), "7", "10")
+	req.Header.Del("x-user-id")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+// TestCreateOperation_CurrencyWithoutLimit checks that operations in
+// currencies without a configured daily limit are rejected.
+func TestCreateOperation_CurrencyWithoutLimit(t *testing.T) {
+	wallet := newWallet(7, "50.00", "0.00")
+	wallet.Currency = "EUR"
+	repo := &MockRepo{
+		GetFunc: func(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
+			return wallet, nil
+		},
+		CreateOperationFunc: func(ctx context.Context, op *domain.Operation,
+			dailyLimit money.Money) error {
+			t.Fatal("unexpected repository call")
+			return nil
+		},
+	}
+
+	for _, app := range []*fiber.App{newTestApp(repo), newTestApp(repo, testLimits)} {
+		for _, path := range []string{"/topups", "/withdrawals"} {
+			resp, err := app.Test(newOperationRequest(path, wallet.WalletID, uuid.New(), "7", "10"))
+			require.NoError(t, err)
+			require.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode, path)
+		}
+	}
+}
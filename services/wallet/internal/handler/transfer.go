@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"payment-system/pkg/logger"
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+// TransferRequest represents the payload for sending funds to the wallet of
+// another user. The amount is a decimal string in the wallet currency.
+type TransferRequest struct {
+	ToWalletID uuid.UUID `json:"to_wallet_id"`
+	Amount     string    `json:"amount"`
+}
+
+// TransferResponse represents a transfer and the available balance of the
+// sending wallet right after it.
+type TransferResponse struct {
+	TransferID   uuid.UUID `json:"transfer_id"`
+	FromWalletID uuid.UUID `json:"from_wallet_id"`
+	ToWalletID   uuid.UUID `json:"to_wallet_id"`
+	Amount       string    `json:"amount"`
+	Currency     string    `json:"currency"`
+	Balance      string    `json:"balance"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// transferFingerprint is the part of a transfer request that identifies it
+// for idempotency.
+type transferFingerprint struct {
+	FromWalletID uuid.UUID `json:"from_wallet_id"`
+	ToWalletID   uuid.UUID `json:"to_wallet_id"`
+	Amount       string    `json:"amount"`
+}
+
+func newTransferResponse(t *domain.WalletTransfer) *TransferResponse {
+	return &TransferResponse{
+		TransferID:   t.TransferID,
+		FromWalletID: t.FromWalletID,
+		ToWalletID:   t.ToWalletID,
+		Amount:       t.Amount.AmountString(),
+		Currency:     string(t.Amount.Currency()),
+		Balance:      t.BalanceAfter.AmountString(),
+		CreatedAt:    t.CreatedAt,
+	}
+}
+
+// CreateTransfer handles POST /v1/wallets/:wallet_id/transfers requests.
+// It moves the amount from the wallet of the path, which must belong to the
+// user, to the wallet of another user in the same currency.
+// Headers required:
+//   - idempotency-key: a unique key to ensure idempotent requests.
+//   - x-user-id: the ID of the user making the request.
+//
+// Idempotency keys are scoped to the user. A request replayed with the same
+// key and body returns the original response, while reusing a key with a
+// different body is rejected with StatusUnprocessableEntity. Transfers over
+// the available balance or between different currencies are rejected with
+// StatusUnprocessableEntity as well.
+func (h *WalletHandler) CreateTransfer(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	idempotencyKey, err := idempotencyKeyFromHeader(c)
+	if err != nil {
+		return err
+	}
+	userID, err := userIDFromHeader(c)
+	if err != nil {
+		return err
+	}
+
+	var request TransferRequest
+	if err := c.BodyParser(&request); err != nil {
+		h.logger.Error("failed to parse transfer request", logger.Error(err))
+		return fiber.NewError(fiber.StatusBadRequest,
+			"invalid JSON body")
+	}
+	if request.ToWalletID == uuid.Nil {
+		return fiber.NewError(fiber.StatusBadRequest,
+			"to_wallet_id is required")
+	}
+
+	wallet, err := h.ownWallet(c, userID)
+	if err != nil {
+		return err
+	}
+	if request.ToWalletID == wallet.WalletID {
+		return fiber.NewError(fiber.StatusBadRequest,
+			"cannot transfer to the same wallet")
+	}
+	amount, err := parseAmount(request.Amount, wallet.Currency)
+	if err != nil {
+		return err
+	}
+
+	// replay the original response if the key was already used.
+	requestHash, err := fingerprint(&transferFingerprint{
+		FromWalletID: wallet.WalletID,
+		ToWalletID:   request.ToWalletID,
+		Amount:       amount.AmountString(),
+	})
+	if err != nil {
+		h.logger.Error("failed to fingerprint transfer request", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create transfer")
+	}
+	existing, err := h.repository.GetTransferByIdempotencyKey(ctx, userID, idempotencyKey)
+	switch {
+	case err == nil:
+		return h.replayTransfer(c, existing, requestHash)
+	case !errors.Is(err, repository.ErrTransferNotFound):
+		h.logger.Error("failed to get transfer by idempotency-key", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create transfer")
+	}
+
+	transfer := &domain.WalletTransfer{
+		TransferID:     uuid.New(),
+		FromWalletID:   wallet.WalletID,
+		ToWalletID:     request.ToWalletID,
+		UserID:         userID,
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		Amount:         amount,
+	}
+	err = h.repository.CreateTransfer(ctx, transfer)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrDuplicateIdempotencyKey):
+		// a concurrent request with the same key created the transfer first.
+		existing, err := h.repository.GetTransferByIdempotencyKey(ctx, userID, idempotencyKey)
+		if err != nil {
+			h.logger.Error("failed to get transfer by idempotency-key", logger.Error(err))
+			return fiber.NewError(fiber.StatusConflict,
+				"a request with the same idempotency-key is in progress")
+		}
+		return h.replayTransfer(c, existing, requestHash)
+	case errors.Is(err, repository.ErrRecipientNotFound):
+		return fiber.NewError(fiber.StatusNotFound,
+			"recipient wallet not found")
+	case errors.Is(err, repository.ErrWalletNotFound):
+		return fiber.NewError(fiber.StatusNotFound,
+			"wallet not found")
+	case errors.Is(err, money.ErrCurrencyMismatch):
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"recipient wallet has a different currency")
+	case errors.Is(err, repository.ErrInsufficientFunds):
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"insufficient funds")
+	default:
+		h.logger.Error("failed to create transfer", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create transfer")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(newTransferResponse(transfer))
+}
+
+// replayTransfer responds to a request whose idempotency key already
+// created the given transfer, provided the request body is the same.
+func (h *WalletHandler) replayTransfer(c *fiber.Ctx, t *domain.WalletTransfer,
+	requestHash string) error {
+	if t.RequestHash != requestHash {
+		return fiber.NewError(fiber.StatusUnprocessableEntity,
+			"idempotency-key already used with a different request")
+	}
+	return c.Status(fiber.StatusCreated).JSON(newTransferResponse(t))
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"payment-system/pkg/money"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+// newTransferRequest builds a transfer request from the wallet.
+func newTransferRequest(walletID uuid.UUID, key uuid.UUID, userID string,
+	toWalletID uuid.UUID, amount string) *http.Request {
+	body, _ := json.Marshal(TransferRequest{ToWalletID: toWalletID, Amount: amount})
+	req := httptest.NewRequest(http.MethodPost, "/wallets/"+walletID.String()+"/transfers",
+		bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("idempotency-key", key.String())
+	req.Header.Set("x-user-id", userID)
+	return req
+}
+
+// TestCreateTransfer checks the responses of transfers to valid requests
+// and to the errors reported by the repository.
+func TestCreateTransfer(t *testing.T) {
+	wallet := newWallet(7, "50.00", "0.00")
+	recipient := uuid.New()
+
+	tests := []struct {
+		name       string
+		userID     string
+		toWalletID uuid.UUID
+		amount     string
+		createErr  error
+		wantStatus int
+	}{
+		{"transfer", "7", recipient, "12.5", nil, fiber.StatusCreated},
+		{"insufficient funds", "7", recipient, "60", repository.ErrInsufficientFunds,
+			fiber.StatusUnprocessableEntity},
+		{"currency mismatch", "7", recipient, "10",
+			fmt.Errorf("%w: EUR wallet", money.ErrCurrencyMismatch), fiber.StatusUnprocessableEntity},
+		{"unknown recipient", "7", recipient, "10", repository.ErrRecipientNotFound,
+			fiber.StatusNotFound},
+		{"same wallet", "7", wallet.WalletID, "10", nil, fiber.StatusBadRequest},
+		{"missing recipient", "7", uuid.Nil, "10", nil, fiber.StatusBadRequest},
+		{"not positive", "7", recipient, "-1", nil, fiber.StatusBadRequest},
+		{"too precise", "7", recipient, "10.005", nil, fiber.StatusBadRequest},
+		{"other user", "8", recipient, "10", nil, fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *domain.WalletTransfer
+			repo := &MockRepo{
+				GetFunc: func(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
+					return wallet, nil
+				},
+				CreateTransferFunc: func(ctx context.Context, tr *domain.WalletTransfer) error {
+					if tt.createErr != nil {
+						return tt.createErr
+					}
+					tr.BalanceAfter = money.MustParse("37.50", "USD")
+					created = tr
+					return nil
+				},
+			}
+			app := newTestApp(repo)
+
+			resp, err := app.Test(newTransferRequest(wallet.WalletID, uuid.New(), tt.userID,
+				tt.toWalletID, tt.amount))
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != fiber.StatusCreated {
+				return
+			}
+
+			require.Equal(t, uint32(7), created.UserID)
+			var body TransferResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			require.Equal(t, created.TransferID, body.TransferID)
+			require.Equal(t, wallet.WalletID, body.FromWalletID)
+			require.Equal(t, recipient, body.ToWalletID)
+			require.Equal(t, "12.50", body.Amount)
+			require.Equal(t, "USD", body.Currency)
+			require.Equal(t, "37.50", body.Balance)
+		})
+	}
+}
+
+// TestCreateTransfer_Replay checks that a transfer replayed with the same
+// idempotency key returns the original transfer, while reusing the key with
+// a different request is rejected.
+func TestCreateTransfer_Replay(t *testing.T) {
+	wallet := newWallet(7, "50.00", "0.00")
+	recipient := uuid.New()
+	key := uuid.New()
+
+	var stored *domain.WalletTransfer
+	creates := 0
+	repo := &MockRepo{
+		GetFunc: func(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
+			return wallet, nil
+		},
+		GetTransferFunc: func(ctx context.Context, userID uint32,
+			k uuid.UUID) (*domain.WalletTransfer, error) {
+			if stored == nil || k != key || userID != stored.UserID {
+				return nil, repository.ErrTransferNotFound
+			}
+			return stored, nil
+		},
+		CreateTransferFunc: func(ctx context.Context, tr *domain.WalletTransfer) error {
+			creates++
+			tr.BalanceAfter = money.MustParse("40.00", "USD")
+			stored = tr
+			return nil
+		},
+	}
+	app := newTestApp(repo)
+
+	resp, err := app.Test(newTransferRequest(wallet.WalletID, key, "7", recipient, "10"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var first TransferResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&first))
+
+	resp, err = app.Test(newTransferRequest(wallet.WalletID, key, "7", recipient, "10.00"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var replayed TransferResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&replayed))
+	require.Equal(t, first.TransferID, replayed.TransferID)
+	require.Equal(t, 1, creates)
+
+	resp, err = app.Test(newTransferRequest(wallet.WalletID, key, "7", uuid.New(), "10"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	require.Equal(t, 1, creates)
+}
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"payment-system/pkg/logger"
+	"payment-system/pkg/money"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+// WalletHandler handles wallet HTTP requests.
+type WalletHandler struct {
+	repository repository.WalletRepo
+	logger     logger.Logger
+
+	topUpDailyLimits      domain.DailyLimits
+	withdrawalDailyLimits domain.DailyLimits
+}
+
+// Option configures a WalletHandler.
+type Option func(*WalletHandler)
+
+// WithDailyLimits sets the largest amount a user may top up and withdraw in
+// each currency in a UTC day. Without this option, or for currencies without
+// a limit, top-ups and withdrawals are rejected.
+func WithDailyLimits(topUp, withdrawal domain.DailyLimits) Option {
+	return func(h *WalletHandler) {
+		h.topUpDailyLimits = topUp
+		h.withdrawalDailyLimits = withdrawal
+	}
+}
+
+// NewWalletHandler creates a new instance of WalletHandler.
+func NewWalletHandler(repository repository.WalletRepo,
+	logger logger.Logger, opts ...Option) *WalletHandler {
+	h := &WalletHandler{
+		repository: repository,
+		logger:     logger,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// WalletRequest represents the payload for creating a new wallet.
+type WalletRequest struct {
+	Currency string `json:"currency"`
+}
+
+// WalletResponse represents a wallet and its balances. Amounts are decimal
+// strings so no precision is lost.
+type WalletResponse struct {
+	WalletID  uuid.UUID `json:"wallet_id"`
+	UserID    uint32    `json:"user_id"`
+	Currency  string    `json:"currency"`
+	Available string    `json:"available"`
+	Held      string    `json:"held"`
+	Total     string    `json:"total"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListWalletsResponse represents the wallets of a user.
+type ListWalletsResponse struct {
+	Wallets []*WalletResponse `json:"wallets"`
+}
+
+func newWalletResponse(w *domain.Wallet) *WalletResponse {
+	return &WalletResponse{
+		WalletID:  w.WalletID,
+		UserID:    w.UserID,
+		Currency:  string(w.Currency),
+		Available: w.Available.AmountString(),
+		Held:      w.Held.AmountString(),
+		Total:     w.Total().AmountString(),
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+}
+
+// CreateWallet handles POST /v1/wallets requests.
+// It creates an empty wallet in the requested currency for the user of the
+// x-user-id header. A user has at most one wallet per currency, creating a
+// second one is rejected with StatusConflict.
+func (h *WalletHandler) CreateWallet(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID, err := userIDFromHeader(c)
+	if err != nil {
+		return err
+	}
+
+	var request WalletRequest
+	if err := c.BodyParser(&request); err != nil {
+		h.logger.Error("failed to parse wallet request", logger.Error(err))
+		return fiber.NewError(fiber.StatusBadRequest,
+			"invalid JSON body")
+	}
+	currency, err := money.ParseCurrency(request.Currency)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest,
+			"currency invalid")
+	}
+
+	wallet := &domain.Wallet{
+		WalletID: uuid.New(),
+		UserID:   userID,
+		Currency: currency,
+	}
+	err = h.repository.CreateWallet(ctx, wallet)
+	if errors.Is(err, repository.ErrWalletExists) {
+		return fiber.NewError(fiber.StatusConflict,
+			"wallet already exists for the currency")
+	}
+	if err != nil {
+		h.logger.Error("failed to create wallet", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to create wallet")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(newWalletResponse(wallet))
+}
+
+// GetWallet handles GET /v1/wallets/:wallet_id requests.
+// It returns the wallet balances when the wallet belongs to the user of the
+// x-user-id header. Wallets of other users are reported as not found so
+// their existence is not disclosed.
+func (h *WalletHandler) GetWallet(c *fiber.Ctx) error {
+	userID, err := userIDFromHeader(c)
+	if err != nil {
+		return err
+	}
+
+	wallet, err := h.ownWallet(c, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newWalletResponse(wallet))
+}
+
+// ListWallets handles GET /v1/wallets requests.
+// It returns the wallets of the user of the x-user-id header.
+func (h *WalletHandler) ListWallets(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID, err := userIDFromHeader(c)
+	if err != nil {
+		return err
+	}
+
+	wallets, err := h.repository.ListWallets(ctx, userID)
+	if err != nil {
+		h.logger.Error("failed to list wallets", logger.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError,
+			"failed to list wallets")
+	}
+
+	response := &ListWalletsResponse{Wallets: make([]*WalletResponse, 0, len(wallets))}
+	for i := range wallets {
+		response.Wallets = append(response.Wallets, newWalletResponse(&wallets[i]))
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// ownWallet returns the wallet of the wallet_id path parameter when it
+// belongs to the user.
+func (h *WalletHandler) ownWallet(c *fiber.Ctx, userID uint32) (*domain.Wallet, error) {
+	walletID, err := uuid.Parse(c.Params("wallet_id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest,
+			"wallet_id invalid")
+	}
+
+	wallet, err := h.repository.GetWallet(c.UserContext(), walletID)
+	if errors.Is(err, repository.ErrWalletNotFound) ||
+		(err == nil && wallet.UserID != userID) {
+		return nil, fiber.NewError(fiber.StatusNotFound,
+			"wallet not found")
+	}
+	if err != nil {
+		h.logger.Error("failed to get wallet", logger.Error(err))
+		return nil, fiber.NewError(fiber.StatusInternalServerError,
+			"failed to get wallet")
+	}
+	return wallet, nil
+}
+
+// userIDFromHeader returns the ID of the user making the request, taken from
+// the required x-user-id header.
+func userIDFromHeader(c *fiber.Ctx) (uint32, error) {
+	strUserID := c.Get("x-user-id")
+	if strUserID == "" {
+		return 0, fiber.NewError(fiber.StatusBadRequest,
+			"x-user-id header is required")
+	}
+	userID, err := strconv.ParseUint(strUserID, 10, 32)
+	if err != nil {
+		return 0, fiber.NewError(fiber.StatusBadRequest,
+			"x-user-id invalid")
+	}
+	return uint32(userID), nil
+}
+
+// idempotencyKeyFromHeader returns the key of the required idempotency-key
+// header.
+func idempotencyKeyFromHeader(c *fiber.Ctx) (uuid.UUID, error) {
+	strIdempotencyKey := c.Get("idempotency-key")
+	if strIdempotencyKey == "" {
+		return uuid.Nil, fiber.NewError(fiber.StatusBadRequest,
+			"idempotency-key header is required")
+	}
+	idempotencyKey, err := uuid.Parse(strIdempotencyKey)
+	if err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusBadRequest,
+			"idempotency-key invalid")
+	}
+	return idempotencyKey, nil
+}
+
+// parseAmount returns the positive amount of a request in the currency of
+// the wallet. Amounts with more decimals than the currency allows are
+// rejected instead of rounded.
+func parseAmount(amount string, currency money.Currency) (money.Money, error) {
+	m, err := money.Parse(amount, string(currency))
+	if errors.Is(err, money.ErrTooPrecise) {
+		return money.Money{}, fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("amount must have at most %d decimals in %s",
+				currency.MinorUnits(), currency))
+	}
+	if err != nil || !m.IsPositive() {
+		return money.Money{}, fiber.NewError(fiber.StatusBadRequest,
+			"amount must be a positive decimal")
+	}
+	return m, nil
+}
+
+// fingerprint returns the hex encoded SHA-256 of the canonical JSON encoding
+// of the request, so formatting differences do not change it.
+func fingerprint(request any) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"payment-system/pkg/logger"
+	"payment-system/pkg/money"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+type MockLogger struct{}
+
+func (l *MockLogger) Debug(msg string, args ...any)  {}
+func (l *MockLogger) Info(msg string, args ...any)   {}
+func (l *MockLogger) Warn(msg string, args ...any)   {}
+func (l *MockLogger) Error(msg string, args ...any)  {}
+func (l *MockLogger) Fatal(msg string, args ...any)  {}
+func (l *MockLogger) With(args ...any) logger.Logger { return l }
+
+type MockRepo struct {
+	CreateFunc func(ctx context.Context, w *domain.Wallet) error
+	GetFunc    func(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error)
+	ListFunc   func(ctx context.Context, userID uint32) ([]domain.Wallet, error)
+
+	CreateOperationFunc func(ctx context.Context, op *domain.Operation, dailyLimit money.Money) error
+	GetOperationFunc    func(ctx context.Context, userID uint32, key uuid.UUID) (*domain.Operation, error)
+
+	CreateTransferFunc func(ctx context.Context, t *domain.WalletTransfer) error
+	GetTransferFunc    func(ctx context.Context, userID uint32, key uuid.UUID) (*domain.WalletTransfer, error)
+}
+
+func (m *MockRepo) CreateWallet(ctx context.Context, w *domain.Wallet) error {
+	if m.CreateFunc != nil {
+		return m.CreateFunc(ctx, w)
+	}
+	return nil
+}
+
+func (m *MockRepo) GetWallet(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
+	if m.GetFunc != nil {
+		return m.GetFunc(ctx, walletID)
+	}
+	return nil, repository.ErrWalletNotFound
+}
+
+func (m *MockRepo) ListWallets(ctx context.Context, userID uint32) ([]domain.Wallet, error) {
+	if m.ListFunc != nil {
+		return m.ListFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepo) CreateOperation(ctx context.Context, op *domain.Operation,
+	dailyLimit money.Money) error {
+	if m.CreateOperationFunc != nil {
+		return m.CreateOperationFunc(ctx, op, dailyLimit)
+	}
+	return nil
+}
+
+func (m *MockRepo) GetOperationByIdempotencyKey(ctx context.Context, userID uint32,
+	key uuid.UUID) (*domain.Operation, error) {
+	if m.GetOperationFunc != nil {
+		return m.GetOperationFunc(ctx, userID, key)
+	}
+	return nil, repository.ErrOperationNotFound
+}
+
+func (m *MockRepo) CreateTransfer(ctx context.Context, t *domain.WalletTransfer) error {
+	if m.CreateTransferFunc != nil {
+		return m.CreateTransferFunc(ctx, t)
+	}
+	return nil
+}
+
+func (m *MockRepo) GetTransferByIdempotencyKey(ctx context.Context, userID uint32,
+	key uuid.UUID) (*domain.WalletTransfer, error) {
+	if m.GetTransferFunc != nil {
+		return m.GetTransferFunc(ctx, userID, key)
+	}
+	return nil, repository.ErrTransferNotFound
+}
+
+// newTestApp registers the wallet routes backed by the mock repository.
+func newTestApp(repo repository.WalletRepo, opts ...Option) *fiber.App {
+	app := fiber.New()
+	h := NewWalletHandler(repo, &MockLogger{}, opts...)
+	app.Post("/wallets", h.CreateWallet)
+	app.Get("/wallets", h.ListWallets)
+	app.Get("/wallets/:wallet_id", h.GetWallet)
+	app.Post("/wallets/:wallet_id/topups", h.CreateTopUp)
+	app.Post("/wallets/:wallet_id/withdrawals", h.CreateWithdrawal)
+	app.Post("/wallets/:wallet_id/transfers", h.CreateTransfer)
+	return app
+}
+
+func newWallet(userID uint32, available, held string) *domain.Wallet {
+	return &domain.Wallet{
+		WalletID:  uuid.New(),
+		UserID:    userID,
+		Currency:  "USD",
+		Available: money.MustParse(available, "USD"),
+		Held:      money.MustParse(held, "USD"),
+	}
+}
+
+// TestCreateWallet checks that a wallet is created in the requested currency
+// and that invalid currencies and duplicated wallets are rejected.
+func TestCreateWallet(t *testing.T) {
+	var created *domain.Wallet
+	repo := &MockRepo{
+		CreateFunc: func(ctx context.Context, w *domain.Wallet) error {
+			if created != nil {
+				return repository.ErrWalletExists
+			}
+			w.Available = money.Zero(w.Currency)
+			w.Held = money.Zero(w.Currency)
+			created = w
+			return nil
+		},
+	}
+	app := newTestApp(repo)
+
+	post := func(currency string) *http.Response {
+		body, _ := json.Marshal(WalletRequest{Currency: currency})
+		req := httptest.NewRequest(http.MethodPost, "/wallets", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-user-id", "7")
+		resp, _ := app.Test(req)
+		return resp
+	}
+
+	resp := post("eur")
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var body WalletResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, created.WalletID, body.WalletID)
+	require.Equal(t, uint32(7), body.UserID)
+	require.Equal(t, "EUR", body.Currency)
+	require.Equal(t, "0.00", body.Available)
+
+	require.Equal(t, fiber.StatusConflict, post("EUR").StatusCode)
+	require.Equal(t, fiber.StatusBadRequest, post("XYZ").StatusCode)
+}
+
+// TestGetWallet checks that the owner can read the wallet balances and that
+// other users, unknown and invalid IDs get the matching error status.
+func TestGetWallet(t *testing.T) {
+	wallet := newWallet(1, "10.00", "2.50")
+	repo := &MockRepo{
+		GetFunc: func(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
+			if walletID == wallet.WalletID {
+				return wallet, nil
+			}
+			if walletID == uuid.Nil {
+				return nil, errors.New("connection refused")
+			}
+			return nil, repository.ErrWalletNotFound
+		},
+	}
+	app := newTestApp(repo)
+
+	get := func(walletID, userID string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/wallets/"+walletID, nil)
+		req.Header.Set("x-user-id", userID)
+		resp, _ := app.Test(req)
+		return resp
+	}
+
+	resp := get(wallet.WalletID.String(), "1")
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var body WalletResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "10.00", body.Available)
+	require.Equal(t, "2.50", body.Held)
+	require.Equal(t, "12.50", body.Total)
+
+	require.Equal(t, fiber.StatusNotFound, get(wallet.WalletID.String(), "2").StatusCode)
+	require.Equal(t, fiber.StatusNotFound, get(uuid.NewString(), "1").StatusCode)
+	require.Equal(t, fiber.StatusBadRequest, get("not-a-uuid", "1").StatusCode)
+	require.Equal(t, fiber.StatusBadRequest, get(wallet.WalletID.String(), "").StatusCode)
+	require.Equal(t, fiber.StatusInternalServerError, get(uuid.Nil.String(), "1").StatusCode)
+}
+
+// TestListWallets checks that the wallets of the user are listed.
+func TestListWallets(t *testing.T) {
+	repo := &MockRepo{
+		ListFunc: func(ctx context.Context, userID uint32) ([]domain.Wallet, error) {
+			require.Equal(t, uint32(3), userID)
+			return []domain.Wallet{*newWallet(3, "1", "0"), *newWallet(3, "5", "0")}, nil
+		},
+	}
+	app := newTestApp(repo)
+
+	req := httptest.NewRequest(http.MethodGet, "/wallets", nil)
+	req.Header.Set("x-user-id", "3")
+	resp, _ := app.Test(req)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body ListWalletsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Wallets, 2)
+	require.Equal(t, "5.00", body.Wallets[1].Available)
+}
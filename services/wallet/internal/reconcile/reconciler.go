@@ -0,0 +1,76 @@
+// Package reconcile checks the ledger account balances against the journal.
+package reconcile
+
+import (
+	"context"
+	"payment-system/pkg/logger"
+	"time"
+
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+// Repo finds the accounts whose balance differs from their postings.
+type Repo interface {
+	ReconcileBalances(ctx context.Context) ([]repository.BalanceMismatch, error)
+}
+
+// Config holds the reconciler settings.
+type Config struct {
+	// Interval is the time between two reconciliations.
+	Interval time.Duration
+}
+
+// Reconciler periodically checks that every account balance equals the sum
+// of its postings, reporting the accounts that don't. Balances are never
+// corrected automatically, a mismatch is a bug to investigate.
+type Reconciler struct {
+	repository Repo
+	logger     logger.Logger
+	cfg        Config
+}
+
+// NewReconciler creates a new instance of Reconciler.
+func NewReconciler(repository Repo, logger logger.Logger, cfg Config) *Reconciler {
+	return &Reconciler{
+		repository: repository,
+		logger:     logger,
+		cfg:        cfg,
+	}
+}
+
+// Start runs the reconciler every interval until the context is cancelled.
+func (r *Reconciler) Start(ctx context.Context) {
+	r.logger.Info("starting ledger reconciler")
+
+	for {
+		if _, err := r.Run(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error("failed to reconcile ledger balances", logger.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("ledger reconciler stopped due to context cancellation")
+			return
+		case <-time.After(r.cfg.Interval):
+		}
+	}
+}
+
+// Run checks the balances once and returns the mismatches found.
+func (r *Reconciler) Run(ctx context.Context) ([]repository.BalanceMismatch, error) {
+	mismatches, err := r.repository.ReconcileBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range mismatches {
+		r.logger.Error("ledger account balance differs from its postings",
+			logger.String("account_id", m.AccountID.String()),
+			logger.String("balance", m.Balance.String()),
+			logger.String("journal_balance", m.JournalBalance.String()))
+	}
+	if len(mismatches) == 0 {
+		r.logger.Debug("ledger balances reconciled")
+	}
+	return mismatches, nil
+}
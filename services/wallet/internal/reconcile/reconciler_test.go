@@ -0,0 +1,42 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/logger"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/wallet/internal/repository"
+)
+
+type MockRepo struct {
+	Mismatches []repository.BalanceMismatch
+	Err        error
+}
+
+func (m *MockRepo) ReconcileBalances(ctx context.Context) ([]repository.BalanceMismatch, error) {
+	return m.Mismatches, m.Err
+}
+
+// TestRun checks that the mismatches found are returned and that errors of
+// the repository are propagated.
+func TestRun(t *testing.T) {
+	mismatch := repository.BalanceMismatch{
+		AccountID:      uuid.New(),
+		Balance:        decimal.RequireFromString("10"),
+		JournalBalance: decimal.RequireFromString("7.5"),
+	}
+	repo := &MockRepo{Mismatches: []repository.BalanceMismatch{mismatch}}
+	r := NewReconciler(repo, logger.NewNoopLogger(), Config{})
+
+	mismatches, err := r.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []repository.BalanceMismatch{mismatch}, mismatches)
+
+	repo.Err = errors.New("connection refused")
+	_, err = r.Run(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"payment-system/pkg/db"
+	"time"
+)
+
+// ErrEventAlreadyProcessed is returned when a consumed event was already
+// applied.
+var ErrEventAlreadyProcessed = errors.New("event already processed")
+
+// ConsumedEvent identifies an event consumed from Kafka by its CloudEvents
+// source and id, which are unique together.
+type ConsumedEvent struct {
+	Source string
+	ID     string
+	Type   string
+}
+
+// markProcessed records the event as processed within the transaction, or
+// returns ErrEventAlreadyProcessed if it already was.
+func markProcessed(ctx context.Context, tx db.Tx, event ConsumedEvent, at time.Time) error {
+	insert := `
+		INSERT INTO wallet.processed_events
+		(source, event_id, event_type, processed_at)
+		VALUES ($1,$2,$3,$4)
+		ON CONFLICT (source, event_id) DO NOTHING
+	`
+	inserted, err := tx.Exec(ctx, insert, event.Source, event.ID, event.Type, at)
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return ErrEventAlreadyProcessed
+	}
+	return nil
+}
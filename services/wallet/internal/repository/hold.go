@@ -0,0 +1,411 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-system/pkg/db"
+	"payment-system/pkg/events"
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+)
+
+var (
+	// ErrHoldExists is returned when the funds of the payment were already
+	// reserved.
+	ErrHoldExists = errors.New("hold already exists for the payment")
+	// ErrHoldNotFound is returned when no funds were reserved for the payment.
+	ErrHoldNotFound = errors.New("hold not found")
+	// ErrHoldNotHeld is returned when the hold was already captured or
+	// released.
+	ErrHoldNotHeld = errors.New("hold is not held")
+	// ErrHoldReleased is returned when capturing a hold that was released,
+	// e.g. by expiry, so the funds of the payment are no longer reserved.
+	ErrHoldReleased = errors.New("hold was released")
+)
+
+// holdExpiredReason is the reason of the holds released by ExpireHolds.
+const holdExpiredReason = "hold expired"
+
+// insufficientBalanceReason is the reason of the reservations refused for
+// lack of available funds.
+const insufficientBalanceReason = "insufficient available balance"
+
+// holdColumns are the columns selected to read a domain.Hold.
+const holdColumns = `id, hold_id, wallet_id, payment_id, amount, currency, status,
+	reason, created_at, updated_at`
+
+// Reservation is a request to hold funds of the user's wallet for a payment.
+type Reservation struct {
+	PaymentID uuid.UUID
+	UserID    uint32
+	Amount    money.Money
+}
+
+// ReserveFunds holds the funds of a payment in the wallet of the user in the
+// payment currency. Within one transaction it records the event as
+// processed, locks the wallet so concurrent reservations of the wallet run
+// one after the other, and either moves the amount from the available to the held account
+// and enqueues funds.reserved, or enqueues funds.insufficient. In the latter
+// case the transaction is committed and ErrInsufficientFunds is returned.
+// It returns ErrEventAlreadyProcessed and ErrHoldExists for redelivered
+// events, leaving the database unchanged.
+func (r *WalletRepository) ReserveFunds(ctx context.Context, event ConsumedEvent,
+	reservation Reservation) (*domain.Hold, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := markProcessed(ctx, tx, event, now); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	hold, reason, err := reserve(ctx, tx, reservation, now)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	var outcome events.Event
+	if hold != nil {
+		outcome = &events.FundsReservedV1{
+			PaymentID:  reservation.PaymentID,
+			UserID:     reservation.UserID,
+			HoldID:     hold.HoldID,
+			Amount:     hold.Amount.AmountString(),
+			Currency:   string(hold.Amount.Currency()),
+			ReservedAt: now,
+		}
+	} else {
+		outcome = &events.FundsInsufficientV1{
+			PaymentID:  reservation.PaymentID,
+			UserID:     reservation.UserID,
+			Amount:     reservation.Amount.AmountString(),
+			Currency:   string(reservation.Amount.Currency()),
+			Reason:     reason,
+			OccurredAt: now,
+		}
+	}
+	if err := r.enqueueEvent(ctx, tx, reservation.PaymentID, outcome); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if hold == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInsufficientFunds, reason)
+	}
+	return hold, nil
+}
+
+// reserve locks the wallet of the reservation and holds the funds, or returns
+// why they can't be held.
+func reserve(ctx context.Context, tx db.Tx, reservation Reservation,
+	now time.Time) (*domain.Hold, string, error) {
+	if !reservation.Amount.IsPositive() {
+		return nil, "amount must be positive", nil
+	}
+
+	wallet, err := lockWallet(ctx, tx, "w.user_id = $1 AND w.currency = $2",
+		reservation.UserID, reservation.Amount.Currency())
+	if errors.Is(err, ErrWalletNotFound) {
+		return nil, fmt.Sprintf("user has no %s wallet", reservation.Amount.Currency()), nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	cmp, err := wallet.Available.Cmp(reservation.Amount)
+	if err != nil {
+		return nil, "", err
+	}
+	if cmp < 0 {
+		return nil, insufficientBalanceReason, nil
+	}
+
+	// the hold is rolled back to the savepoint if the ledger still refuses
+	// the entry, so funds.insufficient can be committed instead.
+	if _, err := tx.Exec(ctx, "SAVEPOINT reserve"); err != nil {
+		return nil, "", err
+	}
+	hold, err := holdFunds(ctx, tx, wallet, reservation, now)
+	if errors.Is(err, ErrInsufficientFunds) {
+		if _, err := tx.Exec(ctx, "ROLLBACK TO SAVEPOINT reserve"); err != nil {
+			return nil, "", err
+		}
+		return nil, insufficientBalanceReason, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return hold, "", nil
+}
+
+// holdFunds inserts the hold of the reservation and moves its amount from
+// the available to the held account of the locked wallet.
+func holdFunds(ctx context.Context, tx db.Tx, wallet *domain.Wallet,
+	reservation Reservation, now time.Time) (*domain.Hold, error) {
+
+	hold := &domain.Hold{
+		HoldID:    uuid.New(),
+		WalletID:  wallet.WalletID,
+		PaymentID: reservation.PaymentID,
+		Amount:    reservation.Amount,
+		Status:    domain.HoldStatusHeld,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	holdInsert := `
+		INSERT INTO wallet.holds
+		(hold_id, wallet_id, payment_id, amount, currency, status, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$7)
+		RETURNING id
+	`
+	if err := tx.QueryRow(ctx, &hold.ID, holdInsert, hold.HoldID, hold.WalletID,
+		hold.PaymentID, hold.Amount, hold.Amount.Currency(), hold.Status, now); err != nil {
+		if db.IsUniqueViolation(err) {
+			return nil, ErrHoldExists
+		}
+		return nil, err
+	}
+
+	if err := postEntry(ctx, tx, &domain.Entry{
+		Kind:        domain.EntryHold,
+		Reference:   reservation.PaymentID.String(),
+		Description: "funds reserved for payment",
+		Postings:    domain.Transfer(wallet.AvailableAccountID, wallet.HeldAccountID, hold.Amount),
+		CreatedAt:   now,
+	}); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// CaptureHold captures the funds held for the payment, moving them from the
+// held account of the wallet to the settlement account. Within one
+// transaction it records the event as processed and settles the hold. It
+// returns ErrEventAlreadyProcessed, ErrHoldNotFound, ErrHoldNotHeld and
+// ErrHoldReleased, leaving the database unchanged.
+func (r *WalletRepository) CaptureHold(ctx context.Context, event ConsumedEvent,
+	paymentID uuid.UUID) (*domain.Hold, error) {
+	return r.settleHoldEvent(ctx, event, paymentID, domain.HoldStatusCaptured, "")
+}
+
+// ReleaseHold releases the funds held for the payment back to the available
+// account of the wallet, like CaptureHold.
+func (r *WalletRepository) ReleaseHold(ctx context.Context, event ConsumedEvent,
+	paymentID uuid.UUID, reason string) (*domain.Hold, error) {
+	return r.settleHoldEvent(ctx, event, paymentID, domain.HoldStatusReleased, reason)
+}
+
+// ExpireHolds releases up to limit holds created before the given time, the
+// oldest first, and enqueues funds.released for each so the payment service
+// fails their payments instead of completing them later. Holds locked by
+// other transactions are skipped, so several replicas can expire holds at the
+// same time.
+func (r *WalletRepository) ExpireHolds(ctx context.Context, before time.Time,
+	limit int) ([]domain.Hold, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + holdColumns + `
+		FROM wallet.holds
+		WHERE status = $1 AND created_at < $2
+		ORDER BY created_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`
+	var rows []holdRow
+	if err := tx.Select(ctx, &rows, query, domain.HoldStatusHeld, before, limit); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	now := time.Now()
+	holds := make([]domain.Hold, 0, len(rows))
+	for i := range rows {
+		hold, err := rows[i].toHold()
+		if err != nil {
+			_ = tx.Rollback(ctx)
+			return nil, err
+		}
+		if err := settleHold(ctx, tx, hold, domain.HoldStatusReleased, holdExpiredReason, now); err != nil {
+			_ = tx.Rollback(ctx)
+			return nil, err
+		}
+		if err := r.enqueueFundsReleased(ctx, tx, hold, now); err != nil {
+			_ = tx.Rollback(ctx)
+			return nil, err
+		}
+		holds = append(holds, *hold)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return holds, nil
+}
+
+// enqueueFundsReleased enqueues funds.released for a hold released by the
+// wallet.
+func (r *WalletRepository) enqueueFundsReleased(ctx context.Context, tx db.Tx,
+	hold *domain.Hold, now time.Time) error {
+	var userID uint32
+	query := `SELECT user_id FROM wallet.wallets WHERE wallet_id = $1`
+	if err := tx.QueryRow(ctx, &userID, query, hold.WalletID); err != nil {
+		return err
+	}
+
+	return r.enqueueEvent(ctx, tx, hold.PaymentID, &events.FundsReleasedV1{
+		PaymentID:  hold.PaymentID,
+		UserID:     userID,
+		HoldID:     hold.HoldID,
+		Amount:     hold.Amount.AmountString(),
+		Currency:   string(hold.Amount.Currency()),
+		Reason:     holdExpiredReason,
+		ReleasedAt: now,
+	})
+}
+
+// settleHoldEvent records the event as processed and settles the hold of the
+// payment in one transaction.
+func (r *WalletRepository) settleHoldEvent(ctx context.Context, event ConsumedEvent,
+	paymentID uuid.UUID, to domain.HoldStatus, reason string) (*domain.Hold, error) {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := markProcessed(ctx, tx, event, now); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + holdColumns + `
+		FROM wallet.holds
+		WHERE payment_id = $1
+		FOR UPDATE
+	`
+	hold, err := getHold(ctx, tx, query, paymentID)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := settleHold(ctx, tx, hold, to, reason, now); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return hold, nil
+}
+
+// settleHold moves a locked HELD hold to CAPTURED or RELEASED and posts the
+// matching ledger entry within the transaction.
+func settleHold(ctx context.Context, tx db.Tx, hold *domain.Hold,
+	to domain.HoldStatus, reason string, now time.Time) error {
+	if hold.Status == domain.HoldStatusReleased && to == domain.HoldStatusCaptured {
+		return fmt.Errorf("%w: hold of payment %s", ErrHoldReleased, hold.PaymentID)
+	}
+	if hold.Status != domain.HoldStatusHeld {
+		return fmt.Errorf("%w: hold of payment %s is %s", ErrHoldNotHeld,
+			hold.PaymentID, hold.Status)
+	}
+
+	// both hold accounts belong to the wallet of the hold.
+	wallet, err := lockWallet(ctx, tx, "w.wallet_id = $1", hold.WalletID)
+	if err != nil {
+		return err
+	}
+
+	entry := &domain.Entry{
+		Reference: hold.PaymentID.String(),
+		CreatedAt: now,
+	}
+	switch to {
+	case domain.HoldStatusCaptured:
+		settlement, err := systemAccount(ctx, tx, domain.AccountSettlement,
+			hold.Amount.Currency())
+		if err != nil {
+			return err
+		}
+		entry.Kind = domain.EntryCapture
+		entry.Description = "held funds captured for completed payment"
+		entry.Postings = domain.Transfer(wallet.HeldAccountID, settlement, hold.Amount)
+	case domain.HoldStatusReleased:
+		entry.Kind = domain.EntryRelease
+		entry.Description = "held funds released"
+		entry.Postings = domain.Transfer(wallet.HeldAccountID, wallet.AvailableAccountID, hold.Amount)
+	default:
+		return fmt.Errorf("invalid hold status %s", to)
+	}
+	if err := postEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	update := `
+		UPDATE wallet.holds
+		SET status = $1, reason = $2, updated_at = $3
+		WHERE hold_id = $4
+	`
+	if _, err := tx.Exec(ctx, update, to, nullString(reason), now, hold.HoldID); err != nil {
+		return err
+	}
+
+	hold.Status = to
+	hold.Reason = nullString(reason)
+	hold.UpdatedAt = now
+	return nil
+}
+
+// holdRow is a row of wallet.holds, converted to a domain.Hold by toHold.
+type holdRow struct {
+	domain.Hold
+	RawAmount   money.Amount `db:"amount"`
+	RawCurrency string       `db:"currency"`
+}
+
+// toHold combines the amount and currency columns into the hold amount.
+func (r *holdRow) toHold() (*domain.Hold, error) {
+	amount, err := money.New(r.RawAmount, r.RawCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("hold %s: %w", r.HoldID, err)
+	}
+	hold := r.Hold
+	hold.Amount = amount
+	return &hold, nil
+}
+
+// getHold reads the single hold selected by the query, or returns
+// ErrHoldNotFound.
+func getHold(ctx context.Context, q rowQuerier, query string,
+	args ...any) (*domain.Hold, error) {
+	var row holdRow
+	if err := q.QueryRow(ctx, &row, query, args...); err != nil {
+		if db.IsNoRows(err) {
+			return nil, ErrHoldNotFound
+		}
+		return nil, err
+	}
+	return row.toHold()
+}
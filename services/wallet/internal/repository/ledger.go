@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"payment-system/pkg/db"
+	"payment-system/pkg/money"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+)
+
+var (
+	// ErrInsufficientFunds is returned when an entry would make the balance of
+	// a wallet account negative.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrAccountNotFound is returned when a posting refers to an account that
+	// does not exist or has another currency.
+	ErrAccountNotFound = errors.New("ledger account not found")
+)
+
+// BalanceMismatch is an account whose balance differs from the sum of its
+// postings.
+type BalanceMismatch struct {
+	AccountID      uuid.UUID    `db:"account_id"`
+	Balance        money.Amount `db:"balance"`
+	JournalBalance money.Amount `db:"journal_balance"`
+}
+
+// PostEntry records the journal entry and applies its postings to the
+// account balances in a single transaction.
+func (r *WalletRepository) PostEntry(ctx context.Context, entry *domain.Entry) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := postEntry(ctx, tx, entry); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return nil
+}
+
+// ReconcileBalances returns the accounts whose balance differs from the sum
+// of their postings. Both are read in a single statement, so entries
+// committed concurrently are either fully included or not at all.
+func (r *WalletRepository) ReconcileBalances(ctx context.Context) ([]BalanceMismatch, error) {
+	query := `
+		SELECT a.account_id, a.balance, COALESCE(SUM(p.amount), 0) AS journal_balance
+		FROM wallet.accounts a
+		LEFT JOIN wallet.postings p ON p.account_id = a.account_id
+		GROUP BY a.account_id, a.balance
+		HAVING a.balance <> COALESCE(SUM(p.amount), 0)
+	`
+
+	var mismatches []BalanceMismatch
+	if err := r.db.Select(ctx, &mismatches, query); err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
+
+// postEntry validates the entry, inserts it with its postings in the journal
+// and updates the account balances within the transaction. Accounts are
+// updated in account ID order so concurrent entries lock them in the same
+// order and can't deadlock.
+func postEntry(ctx context.Context, tx db.Tx, entry *domain.Entry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	if entry.EntryID == uuid.Nil {
+		entry.EntryID = uuid.New()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	// insert journal entry
+	entryInsert := `
+		INSERT INTO wallet.journal_entries (entry_id, kind, reference, description, created_at)
+		VALUES ($1,$2,$3,$4,$5)
+	`
+	if _, err := tx.Exec(ctx, entryInsert, entry.EntryID, entry.Kind,
+		nullString(entry.Reference), nullString(entry.Description), entry.CreatedAt); err != nil {
+		return err
+	}
+
+	// apply postings
+	postings := slices.Clone(entry.Postings)
+	slices.SortStableFunc(postings, func(a, b domain.Posting) int {
+		return bytes.Compare(a.AccountID[:], b.AccountID[:])
+	})
+	for _, p := range postings {
+		if err := applyPosting(ctx, tx, entry, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPosting updates the balance of the posting account and records the
+// posting.
+func applyPosting(ctx context.Context, tx db.Tx, entry *domain.Entry, p domain.Posting) error {
+	accountUpdate := `
+		UPDATE wallet.accounts
+		SET balance = balance + $1, version = version + 1, updated_at = $2
+		WHERE account_id = $3 AND currency = $4
+	`
+	rows, err := tx.Exec(ctx, accountUpdate,
+		p.Amount, entry.CreatedAt, p.AccountID, p.Amount.Currency())
+	if err != nil {
+		if db.IsCheckViolation(err) {
+			return ErrInsufficientFunds
+		}
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %s in %s", ErrAccountNotFound, p.AccountID, p.Amount.Currency())
+	}
+
+	postingInsert := `
+		INSERT INTO wallet.postings (entry_id, account_id, amount, currency, created_at)
+		VALUES ($1,$2,$3,$4,$5)
+	`
+	_, err = tx.Exec(ctx, postingInsert, entry.EntryID, p.AccountID,
+		p.Amount, p.Amount.Currency(), entry.CreatedAt)
+	return err
+}
+
+// systemAccount returns the ID of the system account of the type and
+// currency, creating it on first use.
+func systemAccount(ctx context.Context, tx db.Tx, accountType domain.AccountType,
+	currency money.Currency) (uuid.UUID, error) {
+	insert := `
+		INSERT INTO wallet.accounts
+		(account_id, type, currency, balance, created_at, updated_at)
+		VALUES ($1,$2,$3,0,$4,$4)
+		ON CONFLICT (type, currency) WHERE wallet_id IS NULL DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, insert, uuid.New(), accountType, currency, time.Now()); err != nil {
+		return uuid.Nil, err
+	}
+
+	query := `
+		SELECT account_id
+		FROM wallet.accounts
+		WHERE wallet_id IS NULL AND type = $1 AND currency = $2
+	`
+	var accountID uuid.UUID
+	if err := tx.QueryRow(ctx, &accountID, query, accountType, currency); err != nil {
+		return uuid.Nil, err
+	}
+	return accountID, nil
+}
+
+// nullString returns nil for empty strings so they are stored as NULL.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
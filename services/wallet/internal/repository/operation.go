@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-system/pkg/db"
+	"payment-system/pkg/events"
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+)
+
+var (
+	// ErrOperationNotFound is returned when the requested operation does not
+	// exist.
+	ErrOperationNotFound = errors.New("operation not found")
+	// ErrDuplicateIdempotencyKey is returned when the user already requested
+	// an operation with the same idempotency key.
+	ErrDuplicateIdempotencyKey = errors.New("duplicate idempotency key")
+	// ErrDailyLimitExceeded is returned when the operation would take the
+	// operations of the user in the day over the daily limit.
+	ErrDailyLimitExceeded = errors.New("daily limit exceeded")
+)
+
+// operationColumns are the columns selected to read a domain.Operation.
+const operationColumns = `id, operation_id, wallet_id, user_id, type, idempotency_key,
+	request_hash, amount, currency, balance_after, entry_id, created_at`
+
+// CreateOperation applies a top-up or a withdrawal to the available balance
+// of the wallet. Within one transaction it locks the wallet, checks the
+// daily limit of the user for the operation type and currency, posts the
+// ledger entry against the external account, records the operation and
+// enqueues wallet.credited or wallet.debited. The daily limit must be in the
+// currency of the operation. It returns ErrWalletNotFound, ErrDailyLimitExceeded,
+// ErrInsufficientFunds and ErrDuplicateIdempotencyKey, leaving the database
+// unchanged.
+func (r *WalletRepository) CreateOperation(ctx context.Context, op *domain.Operation,
+	dailyLimit money.Money) error {
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := r.createOperation(ctx, tx, op, dailyLimit); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return nil
+}
+
+// GetOperationByIdempotencyKey returns the operation requested by the given
+// user with the given idempotency key, or ErrOperationNotFound if there is
+// none.
+func (r *WalletRepository) GetOperationByIdempotencyKey(ctx context.Context,
+	userID uint32, idempotencyKey uuid.UUID) (*domain.Operation, error) {
+	query := `
+		SELECT ` + operationColumns + `
+		FROM wallet.operations
+		WHERE user_id = $1 AND idempotency_key = $2
+	`
+
+	var row operationRow
+	if err := r.db.QueryRow(ctx, &row, query, userID, idempotencyKey); err != nil {
+		if db.IsNoRows(err) {
+			return nil, ErrOperationNotFound
+		}
+		return nil, err
+	}
+	return row.toOperation()
+}
+
+func (r *WalletRepository) createOperation(ctx context.Context, tx db.Tx,
+	op *domain.Operation, dailyLimit money.Money) error {
+	now := time.Now()
+
+	// the wallet lock serializes the balance changes of the wallet, so its
+	// balance and the daily usage of the user in its currency can't change
+	// until the transaction ends.
+	wallet, err := lockWallet(ctx, tx, "w.wallet_id = $1", op.WalletID)
+	if err != nil {
+		return err
+	}
+	if wallet.Currency != op.Amount.Currency() {
+		return fmt.Errorf("%w: wallet is %s", money.ErrCurrencyMismatch, wallet.Currency)
+	}
+
+	if err := checkDailyLimit(ctx, tx, op, dailyLimit, now); err != nil {
+		return err
+	}
+
+	external, err := systemAccount(ctx, tx, domain.AccountExternal, wallet.Currency)
+	if err != nil {
+		return err
+	}
+	entry := &domain.Entry{
+		EntryID:   uuid.New(),
+		Reference: op.OperationID.String(),
+		CreatedAt: now,
+	}
+	if op.Type.IsCredit() {
+		entry.Kind = domain.EntryTopUp
+		entry.Description = "wallet top-up"
+		entry.Postings = domain.Transfer(external, wallet.AvailableAccountID, op.Amount)
+		op.BalanceAfter, err = wallet.Available.Add(op.Amount)
+	} else {
+		entry.Kind = domain.EntryWithdrawal
+		entry.Description = "wallet withdrawal"
+		entry.Postings = domain.Transfer(wallet.AvailableAccountID, external, op.Amount)
+		op.BalanceAfter, err = wallet.Available.Sub(op.Amount)
+	}
+	if err != nil {
+		return err
+	}
+	if op.BalanceAfter.IsNegative() {
+		return ErrInsufficientFunds
+	}
+	if err := postEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	// insert operation
+	op.EntryID = entry.EntryID
+	op.CreatedAt = now
+	operationInsert := `
+		INSERT INTO wallet.operations
+		(operation_id, wallet_id, user_id, type, idempotency_key, request_hash,
+			amount, currency, balance_after, entry_id, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		RETURNING id
+	`
+	if err := tx.QueryRow(ctx, &op.ID, operationInsert,
+		op.OperationID,
+		op.WalletID,
+		op.UserID,
+		op.Type,
+		op.IdempotencyKey,
+		op.RequestHash,
+		op.Amount,
+		op.Amount.Currency(),
+		op.BalanceAfter,
+		op.EntryID,
+		now,
+	); err != nil {
+		if db.IsUniqueViolation(err) {
+			return ErrDuplicateIdempotencyKey
+		}
+		return err
+	}
+
+	return r.enqueueEvent(ctx, tx, op.WalletID, operationEvent(op))
+}
+
+// checkDailyLimit returns ErrDailyLimitExceeded when the operation would take
+// the operations of the same type and currency of the user since the start
+// of the UTC day over the limit.
+func checkDailyLimit(ctx context.Context, tx db.Tx, op *domain.Operation,
+	limit money.Money, now time.Time) error {
+	if limit.Currency() != op.Amount.Currency() {
+		return fmt.Errorf("%w: daily limit is in %s", money.ErrCurrencyMismatch,
+			limit.Currency())
+	}
+
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM wallet.operations
+		WHERE user_id = $1 AND type = $2 AND currency = $3 AND created_at >= $4
+	`
+	var rawUsed money.Amount
+	startOfDay := now.UTC().Truncate(24 * time.Hour)
+	if err := tx.QueryRow(ctx, &rawUsed, query,
+		op.UserID, op.Type, op.Amount.Currency(), startOfDay); err != nil {
+		return err
+	}
+	used, err := money.New(rawUsed, string(op.Amount.Currency()))
+	if err != nil {
+		return err
+	}
+
+	total, err := used.Add(op.Amount)
+	if err != nil {
+		return err
+	}
+	cmp, err := total.Cmp(limit)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return fmt.Errorf("%w: %s of %s used today", ErrDailyLimitExceeded, used, limit)
+	}
+	return nil
+}
+
+// operationEvent returns the wallet.credited or wallet.debited event of the
+// operation.
+func operationEvent(op *domain.Operation) events.Event {
+	if op.Type.IsCredit() {
+		return &events.WalletCreditedV1{
+			WalletID:    op.WalletID,
+			UserID:      op.UserID,
+			OperationID: op.OperationID,
+			Kind:        string(op.Type),
+			Amount:      op.Amount.AmountString(),
+			Currency:    string(op.Amount.Currency()),
+			Balance:     op.BalanceAfter.AmountString(),
+			CreditedAt:  op.CreatedAt,
+		}
+	}
+	return &events.WalletDebitedV1{
+		WalletID:    op.WalletID,
+		UserID:      op.UserID,
+		OperationID: op.OperationID,
+		Kind:        string(op.Type),
+		Amount:      op.Amount.AmountString(),
+		Currency:    string(op.Amount.Currency()),
+		Balance:     op.BalanceAfter.AmountString(),
+		DebitedAt:   op.CreatedAt,
+	}
+}
+
+// operationRow is a row of wallet.operations, converted to a
+// domain.Operation by toOperation.
+type operationRow struct {
+	domain.Operation
+	RawAmount       money.Amount `db:"amount"`
+	RawCurrency     string       `db:"currency"`
+	RawBalanceAfter money.Amount `db:"balance_after"`
+}
+
+// toOperation combines the amount columns with the currency.
+func (r *operationRow) toOperation() (*domain.Operation, error) {
+	amount, err := money.New(r.RawAmount, r.RawCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("operation %s: %w", r.OperationID, err)
+	}
+	balanceAfter, err := money.New(r.RawBalanceAfter, r.RawCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("operation %s: %w", r.OperationID, err)
+	}
+	op := r.Operation
+	op.Amount = amount
+	op.BalanceAfter = balanceAfter
+	return &op, nil
+}
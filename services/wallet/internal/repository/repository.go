@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-system/pkg/db"
+	pkgdomain "payment-system/pkg/domain"
+	"payment-system/pkg/events"
+	"payment-system/pkg/money"
+	"payment-system/pkg/outbox"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/wallet/internal/config"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+)
+
+var (
+	// ErrWalletNotFound is returned when the requested wallet does not exist.
+	ErrWalletNotFound = errors.New("wallet not found")
+	// ErrWalletExists is returned when the user already has a wallet in the
+	// currency.
+	ErrWalletExists = errors.New("wallet already exists")
+)
+
+type WalletRepo interface {
+	CreateWallet(ctx context.Context, w *domain.Wallet) error
+	GetWallet(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error)
+	ListWallets(ctx context.Context, userID uint32) ([]domain.Wallet, error)
+	CreateOperation(ctx context.Context, op *domain.Operation, dailyLimit money.Money) error
+	GetOperationByIdempotencyKey(ctx context.Context, userID uint32,
+		idempotencyKey uuid.UUID) (*domain.Operation, error)
+	CreateTransfer(ctx context.Context, t *domain.WalletTransfer) error
+	GetTransferByIdempotencyKey(ctx context.Context, userID uint32,
+		idempotencyKey uuid.UUID) (*domain.WalletTransfer, error)
+}
+
+// walletQuery selects wallets with the balances of their ledger accounts.
+const walletQuery = `
+	SELECT w.id, w.wallet_id, w.user_id, w.currency, w.created_at, w.updated_at,
+		available.balance AS available, held.balance AS held,
+		available.account_id AS available_account_id, held.account_id AS held_account_id
+	FROM wallet.wallets w
+	JOIN wallet.accounts available
+		ON available.wallet_id = w.wallet_id AND available.type = 'AVAILABLE'
+	JOIN wallet.accounts held
+		ON held.wallet_id = w.wallet_id AND held.type = 'HELD'
+`
+
+type WalletRepository struct {
+	db db.DB
+	// contentType is the encoding of the events written to the outbox.
+	contentType string
+}
+
+func NewWalletRepository(db db.DB, contentType string) *WalletRepository {
+	return &WalletRepository{db: db, contentType: contentType}
+}
+
+// CreateWallet inserts the wallet with its AVAILABLE and HELD ledger
+// accounts, both with a zero balance.
+func (r *WalletRepository) CreateWallet(ctx context.Context, w *domain.Wallet) error {
+	// start transaction
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	// insert wallet
+	now := time.Now()
+	walletInsert := `
+		INSERT INTO wallet.wallets (wallet_id, user_id, currency, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5)
+		RETURNING id
+	`
+	if err := tx.QueryRow(ctx, &w.ID, walletInsert,
+		w.WalletID, w.UserID, w.Currency, now, now); err != nil {
+		_ = tx.Rollback(ctx)
+		if db.IsUniqueViolation(err) {
+			return ErrWalletExists
+		}
+		return err
+	}
+
+	// insert ledger accounts
+	accountInsert := `
+		INSERT INTO wallet.accounts
+		(account_id, wallet_id, type, currency, balance, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,0,$5,$5)
+	`
+	w.AvailableAccountID = uuid.New()
+	w.HeldAccountID = uuid.New()
+	accounts := map[domain.AccountType]uuid.UUID{
+		domain.AccountAvailable: w.AvailableAccountID,
+		domain.AccountHeld:      w.HeldAccountID,
+	}
+	for accountType, accountID := range accounts {
+		if _, err := tx.Exec(ctx, accountInsert,
+			accountID, w.WalletID, accountType, w.Currency, now); err != nil {
+			_ = tx.Rollback(ctx)
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+
+	w.Available = money.Zero(w.Currency)
+	w.Held = money.Zero(w.Currency)
+	w.CreatedAt = now
+	w.UpdatedAt = now
+	return nil
+}
+
+// GetWallet returns the wallet with the given ID, or ErrWalletNotFound if it
+// does not exist.
+func (r *WalletRepository) GetWallet(ctx context.Context,
+	walletID uuid.UUID) (*domain.Wallet, error) {
+	query := walletQuery + `WHERE w.wallet_id = $1`
+
+	return getWallet(ctx, r.db, query, walletID)
+}
+
+// ListWallets returns the wallets of the user ordered by currency.
+func (r *WalletRepository) ListWallets(ctx context.Context,
+	userID uint32) ([]domain.Wallet, error) {
+	query := walletQuery + `WHERE w.user_id = $1 ORDER BY w.currency`
+
+	var rows []walletRow
+	if err := r.db.Select(ctx, &rows, query, userID); err != nil {
+		return nil, err
+	}
+
+	wallets := make([]domain.Wallet, 0, len(rows))
+	for i := range rows {
+		w, err := rows[i].toWallet()
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, *w)
+	}
+	return wallets, nil
+}
+
+// enqueueEvent encodes the given event with the configured content type and
+// inserts it in the wallet outbox within the transaction.
+func (r *WalletRepository) enqueueEvent(ctx context.Context, tx db.Tx,
+	aggregateID uuid.UUID, event events.Event) error {
+	payload, err := events.Encode(event, r.contentType)
+	if err != nil {
+		return err
+	}
+
+	return outbox.Enqueue(ctx, tx, &pkgdomain.Outbox{
+		AggregateID:   aggregateID,
+		AggregateType: domain.AggregateWallet,
+		EventType:     event.EventType(),
+		Payload:       payload,
+		ContentType:   r.contentType,
+	}, outbox.WithSchema(config.Schema))
+}
+
+// rowQuerier is implemented by db.DB and db.Tx.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// walletRow is a row selected by walletQuery, converted to a domain.Wallet by
+// toWallet.
+type walletRow struct {
+	domain.Wallet
+	RawAvailable money.Amount `db:"available"`
+	RawHeld      money.Amount `db:"held"`
+}
+
+// toWallet combines the balances with the wallet currency.
+func (r *walletRow) toWallet() (*domain.Wallet, error) {
+	available, err := money.New(r.RawAvailable, string(r.Currency))
+	if err != nil {
+		return nil, fmt.Errorf("wallet %s: %w", r.WalletID, err)
+	}
+	held, err := money.New(r.RawHeld, string(r.Currency))
+	if err != nil {
+		return nil, fmt.Errorf("wallet %s: %w", r.WalletID, err)
+	}
+	wallet := r.Wallet
+	wallet.Available = available
+	wallet.Held = held
+	return &wallet, nil
+}
+
+// lockWallet locks the row of the wallet matching the condition on w and
+// returns the wallet with its balances. Every change to the balances of a
+// wallet is made under this lock. The balances are read in a separate
+// statement, whose snapshot is taken once the lock is granted, so they include
+// the changes of the transactions that held it before; a statement locking
+// the row reads the balances of the joined accounts as they were when it
+// started waiting.
+func lockWallet(ctx context.Context, tx db.Tx, condition string,
+	args ...any) (*domain.Wallet, error) {
+	lock := `SELECT w.wallet_id FROM wallet.wallets w WHERE ` + condition + ` FOR UPDATE`
+	var walletID uuid.UUID
+	if err := tx.QueryRow(ctx, &walletID, lock, args...); err != nil {
+		if db.IsNoRows(err) {
+			return nil, ErrWalletNotFound
+		}
+		return nil, err
+	}
+
+	return getWallet(ctx, tx, walletQuery+`WHERE w.wallet_id = $1`, walletID)
+}
+
+// getWallet reads the single wallet selected by the query, or returns
+// ErrWalletNotFound.
+func getWallet(ctx context.Context, q rowQuerier, query string,
+	args ...any) (*domain.Wallet, error) {
+	var row walletRow
+	if err := q.QueryRow(ctx, &row, query, args...); err != nil {
+		if db.IsNoRows(err) {
+			return nil, ErrWalletNotFound
+		}
+		return nil, err
+	}
+	return row.toWallet()
+}
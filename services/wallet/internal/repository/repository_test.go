@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"payment-system/pkg/db"
+	"payment-system/pkg/events"
+	"payment-system/pkg/kafka"
+	"payment-system/pkg/money"
+	"payment-system/pkg/outbox"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/test-go/testify/require"
+	"github.com/walker-16/payment-system/services/wallet/internal/config"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+)
+
+// testDSNEnv names the variable holding the DSN of a disposable database the
+// repository tests run against. The tests are skipped when it is not set.
+const testDSNEnv = "WALLET_TEST_DB_DSN"
+
+// newTestRepository migrates the test database and returns a repository
+// writing JSON events, together with its connection pool.
+func newTestRepository(t *testing.T) (*WalletRepository, db.DB) {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	ctx := context.Background()
+	conn, err := db.New(ctx, db.Config{DSN: dsn, MaxConns: 10})
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+
+	// the migrations are idempotent, so they are applied on every run.
+	files, err := filepath.Glob("../../migration/*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		require.NoError(t, err)
+		_, err = conn.Exec(ctx, string(migration))
+		require.NoError(t, err, file)
+	}
+	require.NoError(t, outbox.Migrate(ctx, conn, outbox.WithSchema(config.Schema)))
+
+	return NewWalletRepository(conn, kafka.ContentTypeJSON), conn
+}
+
+// newWallet creates an empty wallet in the currency for a new user.
+func newWallet(t *testing.T, repo *WalletRepository, currency money.Currency) *domain.Wallet {
+	t.Helper()
+	wallet := &domain.Wallet{
+		WalletID: uuid.New(),
+		UserID:   rand.Uint32(),
+		Currency: currency,
+	}
+	require.NoError(t, repo.CreateWallet(context.Background(), wallet))
+	return wallet
+}
+
+// newFundedWallet creates a wallet for a new user with the given available
+// balance.
+func newFundedWallet(t *testing.T, repo *WalletRepository, balance money.Money) *domain.Wallet {
+	t.Helper()
+	wallet := newWallet(t, repo, balance.Currency())
+	require.NoError(t, repo.CreateOperation(context.Background(),
+		newOperation(wallet, domain.OperationTopUp, balance), balance))
+	return wallet
+}
+
+// newOperation returns a new operation of the type on the wallet.
+func newOperation(wallet *domain.Wallet, opType domain.OperationType,
+	amount money.Money) *domain.Operation {
+	return &domain.Operation{
+		OperationID:    uuid.New(),
+		WalletID:       wallet.WalletID,
+		UserID:         wallet.UserID,
+		Type:           opType,
+		IdempotencyKey: uuid.New(),
+		Amount:         amount,
+	}
+}
+
+// createConcurrently runs CreateOperation for all operations at the same
+// time and returns their errors.
+func createConcurrently(repo *WalletRepository, ops []*domain.Operation,
+	dailyLimit money.Money) []error {
+	errs := make([]error, len(ops))
+	var wg sync.WaitGroup
+	for i, op := range ops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repo.CreateOperation(context.Background(), op, dailyLimit)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// outboxEventTypes returns the types of the events enqueued for the
+// aggregate.
+func outboxEventTypes(t *testing.T, conn db.DB, aggregateID uuid.UUID) []string {
+	t.Helper()
+	query := `SELECT event_type FROM wallet.outbox WHERE aggregate_id = $1 ORDER BY id`
+
+	var types []string
+	require.NoError(t, conn.Select(context.Background(), &types, query, aggregateID))
+	return types
+}
+
+// TestReserveFunds_Concurrent verifies that two reservations racing for a
+// balance that covers only one of them hold the funds once and commit
+// funds.insufficient for the other.
+func TestReserveFunds_Concurrent(t *testing.T) {
+	repo, conn := newTestRepository(t)
+	ctx := context.Background()
+
+	for range 10 {
+		amount := money.MustParse("10", "USD")
+		wallet := newFundedWallet(t, repo, amount)
+
+		reservations := make([]Reservation, 2)
+		for i := range reservations {
+			reservations[i] = Reservation{
+				PaymentID: uuid.New(),
+				UserID:    wallet.UserID,
+				Amount:    amount,
+			}
+		}
+
+		errs := make([]error, len(reservations))
+		var wg sync.WaitGroup
+		for i := range reservations {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = repo.ReserveFunds(ctx, ConsumedEvent{
+					Source: "/test",
+					ID:     uuid.NewString(),
+					Type:   events.TypePaymentRequestedV1,
+				}, reservations[i])
+			}()
+		}
+		wg.Wait()
+
+		var reserved, insufficient int
+		for i, err := range errs {
+			switch {
+			case err == nil:
+				reserved++
+				require.Equal(t, []string{events.TypeFundsReservedV1},
+					outboxEventTypes(t, conn, reservations[i].PaymentID))
+			case errors.Is(err, ErrInsufficientFunds):
+				insufficient++
+				require.Contains(t, err.Error(), insufficientBalanceReason)
+				require.Equal(t, []string{events.TypeFundsInsufficientV1},
+					outboxEventTypes(t, conn, reservations[i].PaymentID))
+			default:
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		require.Equal(t, 1, reserved)
+		require.Equal(t, 1, insufficient)
+
+		got, err := repo.GetWallet(ctx, wallet.WalletID)
+		require.NoError(t, err)
+		require.True(t, got.Available.IsZero())
+		require.True(t, got.Held.Equal(amount))
+	}
+}
+
+// TestCreateOperation_ConcurrentTopUps verifies that top-ups applied at the
+// same time record the balance each of them left.
+func TestCreateOperation_ConcurrentTopUps(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	amount := money.MustParse("10", "USD")
+	limit := money.MustParse("1000", "USD")
+
+	for range 10 {
+		wallet := newWallet(t, repo, amount.Currency())
+		ops := []*domain.Operation{
+			newOperation(wallet, domain.OperationTopUp, amount),
+			newOperation(wallet, domain.OperationTopUp, amount),
+		}
+		for _, err := range createConcurrently(repo, ops, limit) {
+			require.NoError(t, err)
+		}
+
+		balances := []string{ops[0].BalanceAfter.String(), ops[1].BalanceAfter.String()}
+		slices.Sort(balances)
+		require.Equal(t, []string{"10.00 USD", "20.00 USD"}, balances)
+		for _, op := range ops {
+			stored, err := repo.GetOperationByIdempotencyKey(context.Background(),
+				op.UserID, op.IdempotencyKey)
+			require.NoError(t, err)
+			require.True(t, stored.BalanceAfter.Equal(op.BalanceAfter))
+		}
+	}
+}
+
+// TestCreateOperation_ConcurrentWithdrawals verifies that withdrawals racing
+// for a balance that covers only one of them apply it once and refuse the
+// other with ErrInsufficientFunds.
+func TestCreateOperation_ConcurrentWithdrawals(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	amount := money.MustParse("10", "USD")
+	limit := money.MustParse("1000", "USD")
+
+	for range 10 {
+		wallet := newFundedWallet(t, repo, amount)
+		ops := []*domain.Operation{
+			newOperation(wallet, domain.OperationWithdrawal, amount),
+			newOperation(wallet, domain.OperationWithdrawal, amount),
+		}
+
+		var applied, refused int
+		for i, err := range createConcurrently(repo, ops, limit) {
+			switch {
+			case err == nil:
+				applied++
+				require.True(t, ops[i].BalanceAfter.IsZero())
+			case errors.Is(err, ErrInsufficientFunds):
+				refused++
+			default:
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		require.Equal(t, 1, applied)
+		require.Equal(t, 1, refused)
+	}
+}
+
+// TestCreateTransfer_Concurrent verifies that transfers from a wallet at the
+// same time record the balance each of them left, and that a transfer over
+// the remaining balance is refused with ErrInsufficientFunds.
+func TestCreateTransfer_Concurrent(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+	amount := money.MustParse("10", "USD")
+
+	for range 10 {
+		from := newFundedWallet(t, repo, money.MustParse("20", "USD"))
+		to := newWallet(t, repo, amount.Currency())
+
+		transfers := make([]*domain.WalletTransfer, 3)
+		for i := range transfers {
+			transfers[i] = &domain.WalletTransfer{
+				TransferID:     uuid.New(),
+				FromWalletID:   from.WalletID,
+				ToWalletID:     to.WalletID,
+				UserID:         from.UserID,
+				IdempotencyKey: uuid.New(),
+				Amount:         amount,
+			}
+		}
+
+		errs := make([]error, len(transfers))
+		var wg sync.WaitGroup
+		for i, transfer := range transfers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = repo.CreateTransfer(ctx, transfer)
+			}()
+		}
+		wg.Wait()
+
+		var balances []string
+		var refused int
+		for i, err := range errs {
+			switch {
+			case err == nil:
+				balances = append(balances, transfers[i].BalanceAfter.String())
+			case errors.Is(err, ErrInsufficientFunds):
+				refused++
+			default:
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		slices.Sort(balances)
+		require.Equal(t, []string{"0.00 USD", "10.00 USD"}, balances)
+		require.Equal(t, 1, refused)
+
+		got, err := repo.GetWallet(ctx, to.WalletID)
+		require.NoError(t, err)
+		require.Equal(t, "20.00 USD", got.Available.String())
+	}
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"payment-system/pkg/db"
+	"payment-system/pkg/events"
+	"payment-system/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/walker-16/payment-system/services/wallet/internal/domain"
+)
+
+var (
+	// ErrTransferNotFound is returned when the requested transfer does not
+	// exist.
+	ErrTransferNotFound = errors.New("transfer not found")
+	// ErrRecipientNotFound is returned when the wallet receiving a transfer
+	// does not exist.
+	ErrRecipientNotFound = errors.New("recipient wallet not found")
+	// ErrSameWallet is returned when a transfer sends funds to the wallet
+	// they come from.
+	ErrSameWallet = errors.New("cannot transfer to the same wallet")
+)
+
+// transferColumns are the columns selected to read a domain.WalletTransfer.
+const transferColumns = `id, transfer_id, from_wallet_id, to_wallet_id, user_id, to_user_id,
+	idempotency_key, request_hash, amount, currency, balance_after, entry_id, created_at`
+
+// CreateTransfer moves the amount from the available balance of the sending
+// wallet to the available balance of the receiving one. Within one
+// transaction it locks both wallets, posts the ledger entry, records the
+// transfer and enqueues wallet.transfer_completed. It returns
+// ErrWalletNotFound when the sending wallet does not belong to the user,
+// ErrRecipientNotFound, ErrSameWallet, money.ErrCurrencyMismatch,
+// ErrInsufficientFunds and ErrDuplicateIdempotencyKey, leaving the database
+// unchanged.
+func (r *WalletRepository) CreateTransfer(ctx context.Context, t *domain.WalletTransfer) error {
+	if t.FromWalletID == t.ToWalletID {
+		return ErrSameWallet
+	}
+
+	tx, err := r.db.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := r.createTransfer(ctx, tx, t); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return nil
+}
+
+// GetTransferByIdempotencyKey returns the transfer requested by the given
+// user with the given idempotency key, or ErrTransferNotFound if there is
+// none.
+func (r *WalletRepository) GetTransferByIdempotencyKey(ctx context.Context,
+	userID uint32, idempotencyKey uuid.UUID) (*domain.WalletTransfer, error) {
+	query := `
+		SELECT ` + transferColumns + `
+		FROM wallet.transfers
+		WHERE user_id = $1 AND idempotency_key = $2
+	`
+
+	var row transferRow
+	if err := r.db.QueryRow(ctx, &row, query, userID, idempotencyKey); err != nil {
+		if db.IsNoRows(err) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+	return row.toTransfer()
+}
+
+func (r *WalletRepository) createTransfer(ctx context.Context, tx db.Tx,
+	t *domain.WalletTransfer) error {
+	now := time.Now()
+
+	from, to, err := lockTransferWallets(ctx, tx, t.FromWalletID, t.ToWalletID)
+	if err != nil {
+		return err
+	}
+	if from.UserID != t.UserID {
+		return ErrWalletNotFound
+	}
+	if from.Currency != to.Currency || from.Currency != t.Amount.Currency() {
+		return fmt.Errorf("%w: cannot transfer %s from a %s wallet to a %s wallet",
+			money.ErrCurrencyMismatch, t.Amount.Currency(), from.Currency, to.Currency)
+	}
+
+	t.BalanceAfter, err = from.Available.Sub(t.Amount)
+	if err != nil {
+		return err
+	}
+	if t.BalanceAfter.IsNegative() {
+		return ErrInsufficientFunds
+	}
+
+	entry := &domain.Entry{
+		EntryID:     uuid.New(),
+		Kind:        domain.EntryTransfer,
+		Reference:   t.TransferID.String(),
+		Description: "wallet transfer",
+		Postings:    domain.Transfer(from.AvailableAccountID, to.AvailableAccountID, t.Amount),
+		CreatedAt:   now,
+	}
+	if err := postEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	// insert transfer
+	t.ToUserID = to.UserID
+	t.EntryID = entry.EntryID
+	t.CreatedAt = now
+	transferInsert := `
+		INSERT INTO wallet.transfers
+		(transfer_id, from_wallet_id, to_wallet_id, user_id, to_user_id, idempotency_key,
+			request_hash, amount, currency, balance_after, entry_id, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+		RETURNING id
+	`
+	if err := tx.QueryRow(ctx, &t.ID, transferInsert,
+		t.TransferID,
+		t.FromWalletID,
+		t.ToWalletID,
+		t.UserID,
+		t.ToUserID,
+		t.IdempotencyKey,
+		t.RequestHash,
+		t.Amount,
+		t.Amount.Currency(),
+		t.BalanceAfter,
+		t.EntryID,
+		now,
+	); err != nil {
+		if db.IsUniqueViolation(err) {
+			return ErrDuplicateIdempotencyKey
+		}
+		return err
+	}
+
+	return r.enqueueEvent(ctx, tx, t.FromWalletID, &events.TransferCompletedV1{
+		TransferID:   t.TransferID,
+		FromWalletID: t.FromWalletID,
+		FromUserID:   t.UserID,
+		ToWalletID:   t.ToWalletID,
+		ToUserID:     t.ToUserID,
+		Amount:       t.Amount.AmountString(),
+		Currency:     string(t.Amount.Currency()),
+		CompletedAt:  now,
+	})
+}
+
+// lockTransferWallets locks the rows of both wallets of a transfer and
+// returns them with their balances as of the locks. The wallets are always
+// locked in wallet_id order, so two transfers between the same wallets in
+// opposite directions wait for each other instead of deadlocking.
+func lockTransferWallets(ctx context.Context, tx db.Tx,
+	fromWalletID, toWalletID uuid.UUID) (*domain.Wallet, *domain.Wallet, error) {
+	first, second := fromWalletID, toWalletID
+	if bytes.Compare(first[:], second[:]) > 0 {
+		first, second = second, first
+	}
+
+	locked := make(map[uuid.UUID]*domain.Wallet, 2)
+	for _, walletID := range []uuid.UUID{first, second} {
+		wallet, err := lockWallet(ctx, tx, "w.wallet_id = $1", walletID)
+		if errors.Is(err, ErrWalletNotFound) && walletID == toWalletID {
+			return nil, nil, ErrRecipientNotFound
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		locked[walletID] = wallet
+	}
+	return locked[fromWalletID], locked[toWalletID], nil
+}
+
+// transferRow is a row of wallet.transfers, converted to a
+// domain.WalletTransfer by toTransfer.
+type transferRow struct {
+	domain.WalletTransfer
+	RawAmount       money.Amount `db:"amount"`
+	RawCurrency     string       `db:"currency"`
+	RawBalanceAfter money.Amount `db:"balance_after"`
+}
+
+// toTransfer combines the amount columns with the currency.
+func (r *transferRow) toTransfer() (*domain.WalletTransfer, error) {
+	amount, err := money.New(r.RawAmount, r.RawCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("transfer %s: %w", r.TransferID, err)
+	}
+	balanceAfter, err := money.New(r.RawBalanceAfter, r.RawCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("transfer %s: %w", r.TransferID, err)
+	}
+	t := r.WalletTransfer
+	t.Amount = amount
+	t.BalanceAfter = balanceAfter
+	return &t, nil
+}
@@ -0,0 +1,114 @@
+CREATE SCHEMA IF NOT EXISTS wallet;
+
+-- one wallet per user and currency.
+CREATE TABLE IF NOT EXISTS wallet.wallets (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL UNIQUE,
+    user_id BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_user_currency
+ON wallet.wallets (user_id, currency);
+
+-- ledger accounts. Every wallet owns an AVAILABLE and a HELD account, while
+-- system accounts (wallet_id NULL) are the counterparty of the funds entering
+-- or leaving the wallets. The balance is the sum of the postings of the
+-- account, kept up to date in the same transaction as the postings and
+-- reconciled against them. Wallet balances never go negative.
+CREATE TABLE IF NOT EXISTS wallet.accounts (
+    id BIGSERIAL PRIMARY KEY,
+    account_id UUID NOT NULL UNIQUE,
+    wallet_id UUID REFERENCES wallet.wallets (wallet_id),
+    type VARCHAR(20) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    balance NUMERIC(19,4) NOT NULL DEFAULT 0,
+    version BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT chk_accounts_wallet_balance CHECK (wallet_id IS NULL OR balance >= 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_wallet_type
+ON wallet.accounts (wallet_id, type) WHERE wallet_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_system_type_currency
+ON wallet.accounts (type, currency) WHERE wallet_id IS NULL;
+
+-- immutable double-entry journal. Each entry groups postings whose amounts
+-- sum to zero per currency; a positive amount increases the balance of the
+-- account and a negative one decreases it.
+CREATE TABLE IF NOT EXISTS wallet.journal_entries (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id UUID NOT NULL UNIQUE,
+    kind VARCHAR(30) NOT NULL,
+    reference VARCHAR(255),
+    description TEXT,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_reference
+ON wallet.journal_entries (reference);
+
+CREATE TABLE IF NOT EXISTS wallet.postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id UUID NOT NULL REFERENCES wallet.journal_entries (entry_id),
+    account_id UUID NOT NULL REFERENCES wallet.accounts (account_id),
+    amount NUMERIC(19,4) NOT NULL CHECK (amount <> 0),
+    currency CHAR(3) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_postings_account_id
+ON wallet.postings (account_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_postings_entry_id
+ON wallet.postings (entry_id);
+
+-- the journal is append-only.
+CREATE OR REPLACE FUNCTION wallet.forbid_journal_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'wallet.% is append-only, % is not allowed', TG_TABLE_NAME, TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_journal_entries_immutable ON wallet.journal_entries;
+
+CREATE TRIGGER trg_journal_entries_immutable
+BEFORE UPDATE OR DELETE ON wallet.journal_entries
+FOR EACH ROW
+EXECUTE FUNCTION wallet.forbid_journal_changes();
+
+DROP TRIGGER IF EXISTS trg_postings_immutable ON wallet.postings;
+
+CREATE TRIGGER trg_postings_immutable
+BEFORE UPDATE OR DELETE ON wallet.postings
+FOR EACH ROW
+EXECUTE FUNCTION wallet.forbid_journal_changes();
+
+-- the postings of an entry must balance when the transaction commits.
+CREATE OR REPLACE FUNCTION wallet.check_entry_balanced()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM wallet.postings
+        WHERE entry_id = NEW.entry_id
+        GROUP BY currency
+        HAVING SUM(amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_postings_balanced ON wallet.postings;
+
+CREATE CONSTRAINT TRIGGER trg_postings_balanced
+AFTER INSERT ON wallet.postings
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW
+EXECUTE FUNCTION wallet.check_entry_balanced();
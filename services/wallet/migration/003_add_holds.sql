@@ -0,0 +1,29 @@
+-- funds of a wallet reserved for a payment, moved from the AVAILABLE to the
+-- HELD account of the wallet. A payment has at most one hold.
+CREATE TABLE IF NOT EXISTS wallet.holds (
+    id BIGSERIAL PRIMARY KEY,
+    hold_id UUID NOT NULL UNIQUE,
+    wallet_id UUID NOT NULL REFERENCES wallet.wallets (wallet_id),
+    payment_id UUID NOT NULL UNIQUE,
+    amount NUMERIC(19,4) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'HELD',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_wallet_id
+ON wallet.holds (wallet_id);
+
+-- events consumed from Kafka, recorded in the same transaction as their
+-- effects so redelivered events are applied only once.
+CREATE TABLE IF NOT EXISTS wallet.processed_events (
+    source VARCHAR(255) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (source, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at
+ON wallet.processed_events (processed_at);
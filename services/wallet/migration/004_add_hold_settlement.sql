@@ -0,0 +1,8 @@
+-- why a hold was released, e.g. the payment failure reason or its expiry.
+ALTER TABLE wallet.holds
+ADD COLUMN IF NOT EXISTS reason TEXT;
+
+-- lookup of the expired holds by the hold expiry sweeper.
+CREATE INDEX IF NOT EXISTS idx_holds_held_created_at
+ON wallet.holds (created_at)
+WHERE status = 'HELD';
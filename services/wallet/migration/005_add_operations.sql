@@ -0,0 +1,23 @@
+-- top-ups and withdrawals requested by users. Idempotency keys are scoped to
+-- the user, and the request fingerprint tells replays from key reuse.
+CREATE TABLE IF NOT EXISTS wallet.operations (
+    id BIGSERIAL PRIMARY KEY,
+    operation_id UUID NOT NULL UNIQUE,
+    wallet_id UUID NOT NULL REFERENCES wallet.wallets (wallet_id),
+    user_id BIGINT NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    idempotency_key UUID NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    amount NUMERIC(19,4) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    balance_after NUMERIC(19,4) NOT NULL,
+    entry_id UUID NOT NULL REFERENCES wallet.journal_entries (entry_id),
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_operations_user_idempotency_key
+ON wallet.operations (user_id, idempotency_key);
+
+-- daily limits sum the operations of a user by type and currency.
+CREATE INDEX IF NOT EXISTS idx_operations_user_type_created_at
+ON wallet.operations (user_id, type, currency, created_at);
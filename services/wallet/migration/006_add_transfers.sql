@@ -0,0 +1,21 @@
+-- transfers between the wallets of two users. Idempotency keys are scoped to
+-- the sender, and the request fingerprint tells replays from key reuse.
+CREATE TABLE IF NOT EXISTS wallet.transfers (
+    id BIGSERIAL PRIMARY KEY,
+    transfer_id UUID NOT NULL UNIQUE,
+    from_wallet_id UUID NOT NULL REFERENCES wallet.wallets (wallet_id),
+    to_wallet_id UUID NOT NULL REFERENCES wallet.wallets (wallet_id),
+    user_id BIGINT NOT NULL,
+    to_user_id BIGINT NOT NULL,
+    idempotency_key UUID NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    amount NUMERIC(19,4) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    balance_after NUMERIC(19,4) NOT NULL,
+    entry_id UUID NOT NULL REFERENCES wallet.journal_entries (entry_id),
+    created_at TIMESTAMPTZ NOT NULL,
+    CHECK (from_wallet_id <> to_wallet_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_user_idempotency_key
+ON wallet.transfers (user_id, idempotency_key);